@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// ImageInfo holds the accessibility-relevant attributes of an <img> tag
+type ImageInfo struct {
+	Src        string
+	Alt        string
+	HasAlt     bool // false when the alt attribute is absent, not just empty
+	Width      string
+	Height     string
+	Loading    string
+	Title      string
+	Decorative bool // role="presentation"/"none" or aria-hidden="true"
+	LinkOnly   bool // the image is the only content of a link
+}
+
+// accessibilityIssue is a single problem found on a page
+type accessibilityIssue struct {
+	Issue   string
+	Element string
+	Detail  string
+}
+
+// getImageDetailsFromHTML extracts every <img> with its accessibility attributes
+func getImageDetailsFromHTML(htmlBody string, baseURL *url.URL) ([]ImageInfo, error) {
+	doc, err := html.Parse(strings.NewReader(htmlBody))
+	if err != nil {
+		return nil, err
+	}
+	return getImageDetailsFromDoc(doc, baseURL), nil
+}
+
+// getImageDetailsFromDoc is getImageDetailsFromHTML for an already parsed page
+func getImageDetailsFromDoc(root *html.Node, baseURL *url.URL) []ImageInfo {
+	doc := goquery.NewDocumentFromNode(root)
+
+	var images []ImageInfo
+	doc.Find("img").Each(func(_ int, s *goquery.Selection) {
+		src := s.AttrOr("src", s.AttrOr("data-src", ""))
+		if parsedURL, err := url.Parse(strings.TrimSpace(src)); err == nil && src != "" {
+			src = baseURL.ResolveReference(parsedURL).String()
+		}
+
+		alt, hasAlt := s.Attr("alt")
+		role := strings.ToLower(s.AttrOr("role", ""))
+		images = append(images, ImageInfo{
+			Src:        src,
+			Alt:        strings.TrimSpace(alt),
+			HasAlt:     hasAlt,
+			Width:      s.AttrOr("width", ""),
+			Height:     s.AttrOr("height", ""),
+			Loading:    s.AttrOr("loading", ""),
+			Title:      s.AttrOr("title", ""),
+			Decorative: role == "presentation" || role == "none" || s.AttrOr("aria-hidden", "") == "true",
+			LinkOnly:   isOnlyLinkContent(s),
+		})
+	})
+
+	return images
+}
+
+// isOnlyLinkContent reports whether an image is the sole content of its enclosing link
+func isOnlyLinkContent(img *goquery.Selection) bool {
+	link := img.Closest("a")
+	if link.Length() == 0 {
+		return false
+	}
+	return strings.TrimSpace(link.Text()) == "" && link.Find("img").Length() == 1 && link.AttrOr("aria-label", "") == ""
+}
+
+// findAccessibilityIssues checks a page's images and heading order for common problems
+func findAccessibilityIssues(page PageData) []accessibilityIssue {
+	var issues []accessibilityIssue
+
+	for _, img := range page.Images {
+		switch {
+		case !img.HasAlt:
+			issues = append(issues, accessibilityIssue{Issue: "missing_alt", Element: img.Src})
+		case img.Decorative && img.Alt != "":
+			issues = append(issues, accessibilityIssue{
+				Issue:   "decorative_image_with_alt",
+				Element: img.Src,
+				Detail:  "image is hidden from assistive technology but has alt text: " + img.Alt,
+			})
+		case img.Alt == "" && img.LinkOnly:
+			issues = append(issues, accessibilityIssue{
+				Issue:   "empty_alt_on_linked_image",
+				Element: img.Src,
+				Detail:  "image is the only content of a link, so the link has no accessible name",
+			})
+		case img.Alt == "" && !img.Decorative && img.Title != "":
+			issues = append(issues, accessibilityIssue{
+				Issue:   "empty_alt_with_title",
+				Element: img.Src,
+				Detail:  "image is marked decorative but has a title: " + img.Title,
+			})
+		}
+
+		if img.Width == "" || img.Height == "" {
+			issues = append(issues, accessibilityIssue{Issue: "missing_dimensions", Element: img.Src})
+		}
+	}
+
+	for _, skip := range skippedLevels(page.Outline.flatten()) {
+		issues = append(issues, accessibilityIssue{
+			Issue:   "heading_level_skipped",
+			Element: fmt.Sprintf("h%d", skip.Level),
+			Detail:  fmt.Sprintf("h%d follows h%d", skip.Level, skip.Previous),
+		})
+	}
+
+	return issues
+}
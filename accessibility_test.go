@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestGetImageDetailsFromHTML(t *testing.T) {
+	inputBody := `<html><body>
+		<img src="/logo.png" alt="Boot.dev logo" width="120" height="40" loading="lazy" title="Logo">
+		<img src="/spacer.gif" alt="">
+		<img src="/hero.jpg">
+		<a href="/"><img src="/home.png" alt=""></a>
+		<img src="/divider.png" role="presentation" alt="Divider">
+	</body></html>`
+
+	baseURL, _ := url.Parse("https://blog.boot.dev")
+	actual, err := getImageDetailsFromHTML(inputBody, baseURL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []ImageInfo{
+		{Src: "https://blog.boot.dev/logo.png", Alt: "Boot.dev logo", HasAlt: true, Width: "120", Height: "40", Loading: "lazy", Title: "Logo"},
+		{Src: "https://blog.boot.dev/spacer.gif", HasAlt: true},
+		{Src: "https://blog.boot.dev/hero.jpg"},
+		{Src: "https://blog.boot.dev/home.png", HasAlt: true, LinkOnly: true},
+		{Src: "https://blog.boot.dev/divider.png", Alt: "Divider", HasAlt: true, Decorative: true},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+}
+
+func TestFindAccessibilityIssues(t *testing.T) {
+	page := PageData{
+		Images: []ImageInfo{
+			{Src: "ok.png", Alt: "Fine", HasAlt: true, Width: "10", Height: "10"},
+			{Src: "missing.png", Width: "10", Height: "10"},
+			{Src: "decorative.png", Alt: "Swirl", HasAlt: true, Decorative: true, Width: "10", Height: "10"},
+			{Src: "link.png", HasAlt: true, LinkOnly: true, Width: "10", Height: "10"},
+			{Src: "nosize.png", Alt: "No size", HasAlt: true},
+		},
+		Outline: HeadingOutline{Headings: []Heading{
+			{Level: 1, Text: "Title", Children: []Heading{{Level: 3, Text: "Deep"}, {Level: 2, Text: "Section"}}},
+		}},
+	}
+
+	actual := findAccessibilityIssues(page)
+
+	expected := []accessibilityIssue{
+		{Issue: "missing_alt", Element: "missing.png"},
+		{Issue: "decorative_image_with_alt", Element: "decorative.png", Detail: "image is hidden from assistive technology but has alt text: Swirl"},
+		{Issue: "empty_alt_on_linked_image", Element: "link.png", Detail: "image is the only content of a link, so the link has no accessible name"},
+		{Issue: "missing_dimensions", Element: "nosize.png"},
+		{Issue: "heading_level_skipped", Element: "h3", Detail: "h3 follows h1"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+}
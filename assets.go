@@ -0,0 +1,314 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// assetKind is the type of resource a page loads
+type assetKind string
+
+const (
+	assetImage      assetKind = "image"
+	assetScript     assetKind = "script"
+	assetStylesheet assetKind = "stylesheet"
+	assetFont       assetKind = "font"
+	assetVideo      assetKind = "video"
+	assetAudio      assetKind = "audio"
+	assetIcon       assetKind = "icon"
+)
+
+// Asset represents a resource referenced by a page
+type Asset struct {
+	URL         string
+	Kind        assetKind
+	StatusCode  int    // 0 until the asset has been checked
+	ContentType string // filled in by a HEAD request
+	Size        int64  // Content-Length in bytes, 0 if unknown
+	Error       string
+}
+
+// cssURLPattern matches url(...) references in inline styles and <style> blocks
+var cssURLPattern = regexp.MustCompile(`url\(\s*['"]?([^'")]+?)['"]?\s*\)`)
+
+// fontFacePattern matches @font-face rules so their url() references are typed as fonts
+var fontFacePattern = regexp.MustCompile(`(?is)@font-face\s*{[^}]*}`)
+
+// getAssetsFromHTML extracts every resource a page loads, typed by kind
+func getAssetsFromHTML(htmlBody string, baseURL *url.URL) ([]Asset, error) {
+	doc, err := html.Parse(strings.NewReader(htmlBody))
+	if err != nil {
+		return nil, err
+	}
+	return getAssetsFromDoc(doc, baseURL), nil
+}
+
+// getAssetsFromDoc is getAssetsFromHTML for an already parsed page
+func getAssetsFromDoc(root *html.Node, baseURL *url.URL) []Asset {
+	doc := goquery.NewDocumentFromNode(root)
+
+	var assets []Asset
+	seen := make(map[string]bool)
+	add := func(rawURL string, kind assetKind) {
+		rawURL = strings.TrimSpace(rawURL)
+		if rawURL == "" || strings.HasPrefix(rawURL, "data:") {
+			return
+		}
+		parsedURL, err := url.Parse(rawURL)
+		if err != nil {
+			return
+		}
+		resolved := baseURL.ResolveReference(parsedURL).String()
+		key := string(kind) + " " + resolved
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		assets = append(assets, Asset{URL: resolved, Kind: kind})
+	}
+	addSrcset := func(srcset string, kind assetKind) {
+		for _, candidate := range parseSrcset(srcset) {
+			add(candidate, kind)
+		}
+	}
+
+	// Images, including lazy-loaded and responsive variants
+	doc.Find("img").Each(func(_ int, s *goquery.Selection) {
+		add(s.AttrOr("src", ""), assetImage)
+		add(s.AttrOr("data-src", ""), assetImage)
+		addSrcset(s.AttrOr("srcset", ""), assetImage)
+		addSrcset(s.AttrOr("data-srcset", ""), assetImage)
+	})
+	doc.Find("picture source").Each(func(_ int, s *goquery.Selection) {
+		addSrcset(s.AttrOr("srcset", ""), assetImage)
+	})
+
+	// Media elements and their <source> children
+	doc.Find("video").Each(func(_ int, s *goquery.Selection) {
+		add(s.AttrOr("src", ""), assetVideo)
+		add(s.AttrOr("poster", ""), assetImage)
+		s.Find("source").Each(func(_ int, src *goquery.Selection) {
+			add(src.AttrOr("src", ""), assetVideo)
+		})
+	})
+	doc.Find("audio").Each(func(_ int, s *goquery.Selection) {
+		add(s.AttrOr("src", ""), assetAudio)
+		s.Find("source").Each(func(_ int, src *goquery.Selection) {
+			add(src.AttrOr("src", ""), assetAudio)
+		})
+	})
+
+	doc.Find("script[src]").Each(func(_ int, s *goquery.Selection) {
+		add(s.AttrOr("src", ""), assetScript)
+	})
+
+	doc.Find("link[href]").Each(func(_ int, s *goquery.Selection) {
+		if kind, ok := linkAssetKind(s.AttrOr("rel", ""), s.AttrOr("as", ""), s.AttrOr("href", "")); ok {
+			add(s.AttrOr("href", ""), kind)
+		}
+	})
+
+	// CSS background images in style attributes
+	doc.Find("[style]").Each(func(_ int, s *goquery.Selection) {
+		for _, ref := range cssURLs(s.AttrOr("style", "")) {
+			add(ref, assetImage)
+		}
+	})
+
+	// Fonts and images referenced from <style> blocks
+	doc.Find("style").Each(func(_ int, s *goquery.Selection) {
+		css := s.Text()
+		fonts := make(map[string]bool)
+		for _, rule := range fontFacePattern.FindAllString(css, -1) {
+			for _, ref := range cssURLs(rule) {
+				fonts[ref] = true
+			}
+		}
+		for _, ref := range cssURLs(css) {
+			if fonts[ref] || isFontPath(ref) {
+				add(ref, assetFont)
+			} else {
+				add(ref, assetImage)
+			}
+		}
+	})
+
+	return assets
+}
+
+// linkAssetKind determines the asset kind of a <link> element from its rel and as attributes
+func linkAssetKind(rel, as, href string) (assetKind, bool) {
+	for _, r := range strings.Fields(strings.ToLower(rel)) {
+		switch r {
+		case "stylesheet":
+			return assetStylesheet, true
+		case "icon", "apple-touch-icon", "mask-icon":
+			return assetIcon, true
+		case "preload", "prefetch":
+			switch strings.ToLower(as) {
+			case "font":
+				return assetFont, true
+			case "image":
+				return assetImage, true
+			case "script":
+				return assetScript, true
+			case "style":
+				return assetStylesheet, true
+			}
+			if isFontPath(href) {
+				return assetFont, true
+			}
+		}
+	}
+	return "", false
+}
+
+// parseSrcset returns the candidate URLs of a srcset attribute, dropping width/density descriptors
+func parseSrcset(srcset string) []string {
+	var urls []string
+	for _, candidate := range strings.Split(srcset, ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) > 0 {
+			urls = append(urls, fields[0])
+		}
+	}
+	return urls
+}
+
+// cssURLs returns the url(...) references in a CSS fragment
+func cssURLs(css string) []string {
+	var urls []string
+	for _, match := range cssURLPattern.FindAllStringSubmatch(css, -1) {
+		urls = append(urls, match[1])
+	}
+	return urls
+}
+
+// isFontPath reports whether a URL looks like a web font file
+func isFontPath(rawURL string) bool {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(path.Ext(parsedURL.Path)) {
+	case ".woff", ".woff2", ".ttf", ".otf", ".eot":
+		return true
+	}
+	return false
+}
+
+// assetChecker sends HEAD requests for assets, caching results since pages share most assets
+type assetChecker struct {
+	client    *http.Client
+	userAgent string
+	mu        sync.Mutex
+	cache     map[string]Asset
+}
+
+// newAssetChecker creates an assetChecker with an empty cache
+func newAssetChecker() *assetChecker {
+	return &assetChecker{
+		client:    &http.Client{},
+		userAgent: userAgent,
+		cache:     make(map[string]Asset),
+	}
+}
+
+// check fills in status, size and content type for each asset
+func (c *assetChecker) check(assets []Asset) []Asset {
+	checked := make([]Asset, len(assets))
+	for i, asset := range assets {
+		checked[i] = c.checkOne(asset)
+	}
+	return checked
+}
+
+// checkOne returns the cached result for an asset URL, fetching it on first use
+func (c *assetChecker) checkOne(asset Asset) Asset {
+	c.mu.Lock()
+	cached, ok := c.cache[asset.URL]
+	c.mu.Unlock()
+	if ok {
+		cached.Kind = asset.Kind
+		return cached
+	}
+
+	result := asset
+	resp, err := c.head(asset.URL)
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.StatusCode = resp.StatusCode
+		result.ContentType = resp.Header.Get("Content-Type")
+		if resp.ContentLength > 0 {
+			result.Size = resp.ContentLength
+		}
+		if resp.StatusCode >= 400 {
+			result.Error = fmt.Sprintf("error status code: %d", resp.StatusCode)
+		}
+	}
+
+	c.mu.Lock()
+	c.cache[asset.URL] = result
+	c.mu.Unlock()
+	return result
+}
+
+// head sends a HEAD request for the given URL, falling back to a one-byte ranged GET
+// for servers that do not allow HEAD
+func (c *assetChecker) head(rawURL string) (*http.Response, error) {
+	resp, err := c.request("HEAD", rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusMethodNotAllowed && resp.StatusCode != http.StatusNotImplemented {
+		return resp, nil
+	}
+	return c.request("GET", rawURL)
+}
+
+// request sends a bodiless request for the given URL; GETs ask for the first byte only
+// and report the full size from Content-Range
+func (c *assetChecker) request(method, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequest(method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	if method == "GET" {
+		req.Header.Set("Range", "bytes=0-0")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusPartialContent {
+		resp.StatusCode = http.StatusOK
+		resp.ContentLength = contentRangeSize(resp.Header.Get("Content-Range"))
+	}
+	return resp, nil
+}
+
+// contentRangeSize returns the complete length from a "bytes 0-0/1234" header, or -1 if unknown
+func contentRangeSize(contentRange string) int64 {
+	_, total, ok := strings.Cut(contentRange, "/")
+	if !ok {
+		return -1
+	}
+	size, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return size
+}
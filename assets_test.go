@@ -0,0 +1,168 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGetAssetsFromHTMLAllKinds(t *testing.T) {
+	inputBody := `<html><head>
+		<link rel="stylesheet" href="/css/site.css">
+		<link rel="icon" href="/favicon.ico">
+		<link rel="preload" href="/fonts/inter.woff2" as="font">
+		<script src="/js/app.js"></script>
+		<style>
+			@font-face { font-family: Inter; src: url('/fonts/inter-bold.woff2'); }
+			.hero { background: url("/img/hero.jpg"); }
+		</style>
+	</head><body>
+		<img src="/img/logo.png" srcset="/img/logo-2x.png 2x, /img/logo-3x.png 3x">
+		<img data-src="/img/lazy.jpg">
+		<picture><source srcset="/img/photo.webp 800w"><img src="/img/photo.jpg"></picture>
+		<div style="background-image: url(/img/banner.png)"></div>
+		<video poster="/img/poster.jpg"><source src="/media/clip.mp4"></video>
+		<audio src="/media/theme.mp3"></audio>
+		<img src="data:image/gif;base64,R0lGODlhAQABAAAAACw=">
+	</body></html>`
+
+	baseURL, _ := url.Parse("https://blog.boot.dev")
+	actual, err := getAssetsFromHTML(inputBody, baseURL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Asset{
+		{URL: "https://blog.boot.dev/img/logo.png", Kind: assetImage},
+		{URL: "https://blog.boot.dev/img/logo-2x.png", Kind: assetImage},
+		{URL: "https://blog.boot.dev/img/logo-3x.png", Kind: assetImage},
+		{URL: "https://blog.boot.dev/img/lazy.jpg", Kind: assetImage},
+		{URL: "https://blog.boot.dev/img/photo.jpg", Kind: assetImage},
+		{URL: "https://blog.boot.dev/img/photo.webp", Kind: assetImage},
+		{URL: "https://blog.boot.dev/img/poster.jpg", Kind: assetImage},
+		{URL: "https://blog.boot.dev/media/clip.mp4", Kind: assetVideo},
+		{URL: "https://blog.boot.dev/media/theme.mp3", Kind: assetAudio},
+		{URL: "https://blog.boot.dev/js/app.js", Kind: assetScript},
+		{URL: "https://blog.boot.dev/css/site.css", Kind: assetStylesheet},
+		{URL: "https://blog.boot.dev/favicon.ico", Kind: assetIcon},
+		{URL: "https://blog.boot.dev/fonts/inter.woff2", Kind: assetFont},
+		{URL: "https://blog.boot.dev/img/banner.png", Kind: assetImage},
+		{URL: "https://blog.boot.dev/fonts/inter-bold.woff2", Kind: assetFont},
+		{URL: "https://blog.boot.dev/img/hero.jpg", Kind: assetImage},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+}
+
+func TestGetAssetsFromHTMLDeduplicates(t *testing.T) {
+	inputBody := `<html><body><img src="/logo.png"><img src="/logo.png"></body></html>`
+
+	baseURL, _ := url.Parse("https://blog.boot.dev")
+	actual, err := getAssetsFromHTML(inputBody, baseURL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(actual) != 1 {
+		t.Errorf("expected 1 asset, got %d: %+v", len(actual), actual)
+	}
+}
+
+func TestParseSrcset(t *testing.T) {
+	actual := parseSrcset("small.jpg 480w, medium.jpg 800w,large.jpg")
+	expected := []string{"small.jpg", "medium.jpg", "large.jpg"}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
+
+func TestAssetCheckerRecordsSizeAndType(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if r.Method != "HEAD" {
+			t.Errorf("expected HEAD request, got %s", r.Method)
+		}
+		if r.URL.Path == "/missing.png" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Content-Length", "2048")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	checker := newAssetChecker()
+	assets := []Asset{
+		{URL: server.URL + "/logo.png", Kind: assetImage},
+		{URL: server.URL + "/missing.png", Kind: assetImage},
+		{URL: server.URL + "/logo.png", Kind: assetIcon},
+	}
+	checked := checker.check(assets)
+
+	if checked[0].StatusCode != 200 || checked[0].ContentType != "image/png" || checked[0].Size != 2048 {
+		t.Errorf("unexpected result for logo: %+v", checked[0])
+	}
+	if checked[1].StatusCode != 404 || checked[1].Error == "" {
+		t.Errorf("expected 404 error for missing asset, got %+v", checked[1])
+	}
+	if checked[2].Kind != assetIcon || checked[2].Size != 2048 {
+		t.Errorf("expected cached result to keep its own kind, got %+v", checked[2])
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 HEAD requests with caching, got %d", requests)
+	}
+}
+
+func TestAssetCheckerFallsBackToRangedGet(t *testing.T) {
+	tests := []struct {
+		name       string
+		headStatus int
+		getRanged  bool
+		wantStatus int
+		wantSize   int64
+	}{
+		{name: "method not allowed", headStatus: http.StatusMethodNotAllowed, getRanged: true, wantStatus: 200, wantSize: 4096},
+		{name: "not implemented", headStatus: http.StatusNotImplemented, getRanged: true, wantStatus: 200, wantSize: 4096},
+		{name: "range ignored", headStatus: http.StatusMethodNotAllowed, getRanged: false, wantStatus: 200, wantSize: 4096},
+		{name: "head works", headStatus: http.StatusOK, wantStatus: 200, wantSize: 4096},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "image/png")
+				if r.Method == "HEAD" {
+					if tc.headStatus == http.StatusOK {
+						w.Header().Set("Content-Length", "4096")
+					}
+					w.WriteHeader(tc.headStatus)
+					return
+				}
+				if r.Header.Get("Range") != "bytes=0-0" {
+					t.Errorf("expected a one-byte range, got %q", r.Header.Get("Range"))
+				}
+				if tc.getRanged {
+					w.Header().Set("Content-Range", "bytes 0-0/4096")
+					w.WriteHeader(http.StatusPartialContent)
+					w.Write([]byte{0})
+					return
+				}
+				w.Header().Set("Content-Length", "4096")
+				w.Write(make([]byte, 4096))
+			}))
+			defer server.Close()
+
+			checked := newAssetChecker().check([]Asset{{URL: server.URL + "/logo.png", Kind: assetImage}})
+			if checked[0].StatusCode != tc.wantStatus || checked[0].Size != tc.wantSize || checked[0].Error != "" {
+				t.Errorf("got %+v, want status %d and size %d", checked[0], tc.wantStatus, tc.wantSize)
+			}
+		})
+	}
+}
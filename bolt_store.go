@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"sync/atomic"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets of the page database
+var (
+	pagesBucket   = []byte("pages")   // JSON-encoded PageData by normalized URL
+	queueBucket   = []byte("queue")   // JSON-encoded queuedURL by big-endian queue position
+	contentBucket = []byte("content") // first normalized URL by content hash
+)
+
+// boltStore keeps pages, the crawl queue and content hashes in an embedded bbolt database
+// file so crawls aren't limited by RAM. Writes from concurrent crawlers are batched into
+// shared transactions.
+type boltStore struct {
+	db       *bolt.DB
+	pages    atomic.Int64
+	queueN   atomic.Int64
+	tempPath string // file to remove on close, for a temporary store
+}
+
+// openBoltStore opens or creates a page database at path. Unless keep is set, pages and
+// queued URLs from an earlier crawl are discarded so the crawl starts fresh.
+func openBoltStore(path string, keep bool) (*boltStore, error) {
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &boltStore{db: db}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{pagesBucket, queueBucket, contentBucket} {
+			if !keep && tx.Bucket(name) != nil {
+				if err := tx.DeleteBucket(name); err != nil {
+					return err
+				}
+			}
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		s.pages.Store(int64(tx.Bucket(pagesBucket).Stats().KeyN))
+		s.queueN.Store(int64(tx.Bucket(queueBucket).Stats().KeyN))
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// openTempBoltStore creates a page database in a temporary file that is removed on close
+func openTempBoltStore() (*boltStore, error) {
+	file, err := os.CreateTemp("", "crawler-pages-*.db")
+	if err != nil {
+		return nil, err
+	}
+	file.Close()
+
+	s, err := openBoltStore(file.Name(), false)
+	if err != nil {
+		os.Remove(file.Name())
+		return nil, err
+	}
+	s.tempPath = file.Name()
+	return s, nil
+}
+
+// emptyPage is the encoded placeholder stored for queued URLs
+var emptyPage, _ = json.Marshal(PageData{})
+
+func (s *boltStore) put(normalizedURL string, pageData PageData) error {
+	data, err := json.Marshal(pageData)
+	if err != nil {
+		return err
+	}
+
+	isNew := false
+	err = s.db.Batch(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(pagesBucket)
+		isNew = bucket.Get([]byte(normalizedURL)) == nil
+		return bucket.Put([]byte(normalizedURL), data)
+	})
+	if err == nil && isNew {
+		s.pages.Add(1)
+	}
+	return err
+}
+
+// putAll writes the pages in one transaction, without waiting to share it with other writers
+func (s *boltStore) putAll(pages map[string]PageData) error {
+	newCount := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(pagesBucket)
+		for pageURL, pageData := range pages {
+			data, err := json.Marshal(pageData)
+			if err != nil {
+				return err
+			}
+			if bucket.Get([]byte(pageURL)) == nil {
+				newCount++
+			}
+			if err := bucket.Put([]byte(pageURL), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		s.pages.Add(int64(newCount))
+	}
+	return err
+}
+
+func (s *boltStore) get(normalizedURL string) (PageData, bool, error) {
+	var pageData PageData
+	found := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(pagesBucket).Get([]byte(normalizedURL))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &pageData)
+	})
+	return pageData, found, err
+}
+
+func (s *boltStore) remove(normalizedURL string) error {
+	existed := false
+	err := s.db.Batch(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(pagesBucket)
+		existed = bucket.Get([]byte(normalizedURL)) != nil
+		return bucket.Delete([]byte(normalizedURL))
+	})
+	if err == nil && existed {
+		s.pages.Add(-1)
+	}
+	return err
+}
+
+func (s *boltStore) count() int {
+	return int(s.pages.Load())
+}
+
+// each decodes one page at a time, so only the current page is held in memory
+func (s *boltStore) each(fn func(string, PageData) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(pagesBucket).ForEach(func(key, data []byte) error {
+			var pageData PageData
+			if err := json.Unmarshal(data, &pageData); err != nil {
+				return err
+			}
+			return fn(string(key), pageData)
+		})
+	})
+}
+
+// queueKey encodes a queue position so keys sort in queue order
+func queueKey(seq uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, seq)
+}
+
+func (s *boltStore) enqueue(urls []queuedURL) ([]bool, error) {
+	added := make([]bool, len(urls))
+	newCount := 0
+	err := s.db.Batch(func(tx *bolt.Tx) error {
+		clear(added)
+		newCount = 0
+		pages, queue := tx.Bucket(pagesBucket), tx.Bucket(queueBucket)
+		for i, u := range urls {
+			if pages.Get([]byte(u.Key)) != nil {
+				continue
+			}
+			if err := pages.Put([]byte(u.Key), emptyPage); err != nil {
+				return err
+			}
+			seq, err := queue.NextSequence()
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(u)
+			if err != nil {
+				return err
+			}
+			if err := queue.Put(queueKey(seq), data); err != nil {
+				return err
+			}
+			added[i] = true
+			newCount++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.pages.Add(int64(newCount))
+	s.queueN.Add(int64(newCount))
+	return added, nil
+}
+
+func (s *boltStore) queued(from uint64, limit int) ([]queuedURL, error) {
+	var urls []queuedURL
+	err := s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(queueBucket).Cursor()
+		for key, data := cursor.Seek(queueKey(from)); key != nil && len(urls) < limit; key, data = cursor.Next() {
+			var u queuedURL
+			if err := json.Unmarshal(data, &u); err != nil {
+				return err
+			}
+			u.seq = binary.BigEndian.Uint64(key)
+			urls = append(urls, u)
+		}
+		return nil
+	})
+	return urls, err
+}
+
+func (s *boltStore) dequeue(seq uint64) error {
+	existed := false
+	err := s.db.Batch(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(queueBucket)
+		existed = bucket.Get(queueKey(seq)) != nil
+		return bucket.Delete(queueKey(seq))
+	})
+	if err == nil && existed {
+		s.queueN.Add(-1)
+	}
+	return err
+}
+
+func (s *boltStore) queueLen() int {
+	return int(s.queueN.Load())
+}
+
+func (s *boltStore) claimContent(contentHash, normalizedURL string) (string, error) {
+	original := normalizedURL
+	err := s.db.Batch(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(contentBucket)
+		if existing := bucket.Get([]byte(contentHash)); existing != nil {
+			original = string(existing)
+			return nil
+		}
+		original = normalizedURL
+		return bucket.Put([]byte(contentHash), []byte(normalizedURL))
+	})
+	return original, err
+}
+
+func (s *boltStore) close() error {
+	err := s.db.Close()
+	if s.tempPath != "" {
+		if removeErr := os.Remove(s.tempPath); err == nil {
+			err = removeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// findBrokenLinks returns every link from a crawled page to a crawled page that failed,
+// looking targets up by the crawl's normalization policy
+func findBrokenLinks(pages pageStore, policy normalizePolicy) ([]brokenLink, error) {
+	var links []brokenLink
+	err := pages.each(func(pageURL string, pageData PageData) error {
+		for _, link := range pageData.OutgoingLinks {
+			targetURL, err := policy.normalize(link)
+			if err != nil {
+				continue
+			}
+			target, crawled, err := pages.get(targetURL)
+			if err != nil {
+				return err
+			}
+			if crawled && isBroken(target) {
+				links = append(links, brokenLink{
+					Source: displayURL(pageURL),
+					Target: link,
+					Status: target.StatusCode,
+					Error:  target.FetchError,
+				})
+			}
+		}
+		return nil
+	})
+	return links, err
+}
+
+// runCheckLinks implements "crawler check-links [flags] <url>" and returns the exit code
+func runCheckLinks(args []string) int {
+	flags := flag.NewFlagSet("check-links", flag.ContinueOnError)
+	opts := addCrawlFlags(flags)
+	jsonOut := flags.String("json", "", "also write the broken links as JSON to this file")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: crawler check-links [flags] <url>")
+		fmt.Fprintln(flags.Output(), "Crawls the site without writing reports and lists links to pages that failed.")
+		fmt.Fprintln(flags.Output(), "Exits 3 if any link is broken.")
+		flags.PrintDefaults()
+	}
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return parseExitCode(err)
+	}
+	if len(positional) != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	cfg, err := opts.newConfig(positional[0], newMemoryStore(nil))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	if err := cfg.enqueue(positional[0], 0); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitCrawlError
+	}
+	if err := cfg.crawl(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitCrawlError
+	}
+
+	if err := cfg.startPageError(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitCrawlError
+	}
+	links, err := findBrokenLinks(cfg.pages, cfg.normalizer)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error finding broken links: %v\n", err)
+		return exitCrawlError
+	}
+
+	fmt.Printf("Checked %d pages, found %d broken links\n", cfg.pages.count(), len(links))
+	for _, link := range links {
+		problem := link.Error
+		if problem == "" {
+			problem = formatStatus(link.Status)
+		}
+		fmt.Printf("  %s -> %s: %s\n", link.Source, link.Target, problem)
+	}
+
+	if *jsonOut != "" {
+		if links == nil {
+			links = []brokenLink{}
+		}
+		data, err := json.MarshalIndent(links, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error encoding broken links: %v\n", err)
+			return exitCrawlError
+		}
+		if err := os.WriteFile(*jsonOut, append(data, '\n'), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing broken links: %v\n", err)
+			return exitCrawlError
+		}
+		fmt.Printf("Broken links written to: %s\n", *jsonOut)
+	}
+
+	if len(links) > 0 {
+		return exitBrokenLinks
+	}
+	return exitOK
+}
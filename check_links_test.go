@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindBrokenLinks(t *testing.T) {
+	pages := map[string]PageData{
+		"example.com": {
+			URL:           "https://example.com",
+			OutgoingLinks: []string{"https://example.com/ok", "https://example.com/gone", "https://example.com/down", "https://example.com/uncrawled"},
+		},
+		"example.com/ok":   {URL: "https://example.com/ok", StatusCode: 200},
+		"example.com/gone": {URL: "https://example.com/gone", StatusCode: 404, FetchError: "error status code: 404"},
+		"example.com/down": {URL: "https://example.com/down", FetchError: "connection refused"},
+	}
+
+	links, err := findBrokenLinks(newMemoryStore(pages), normalizePolicy{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []brokenLink{
+		{Source: "example.com", Target: "https://example.com/gone", Status: 404, Error: "error status code: 404"},
+		{Source: "example.com", Target: "https://example.com/down", Error: "connection refused"},
+	}
+	if !reflect.DeepEqual(links, expected) {
+		t.Errorf("expected %+v, got %+v", expected, links)
+	}
+}
+
+func TestFindBrokenLinksNone(t *testing.T) {
+	pages := map[string]PageData{
+		"example.com":   {URL: "https://example.com", OutgoingLinks: []string{"https://example.com/a"}},
+		"example.com/a": {URL: "https://example.com/a"},
+	}
+	links, err := findBrokenLinks(newMemoryStore(pages), normalizePolicy{})
+	if err != nil || len(links) != 0 {
+		t.Errorf("expected no broken links, got %+v (%v)", links, err)
+	}
+}
+
+func TestFindBrokenLinksUsesCrawlPolicy(t *testing.T) {
+	// The crawl kept queries, so only page 2 of the list failed
+	pages := map[string]PageData{
+		"example.com": {
+			URL:           "https://example.com",
+			OutgoingLinks: []string{"https://example.com/list", "https://example.com/list?page=2"},
+		},
+		"example.com/list":        {URL: "https://example.com/list", StatusCode: 200},
+		"example.com/list?page=2": {URL: "https://example.com/list?page=2", StatusCode: 500, FetchError: "error status code: 500"},
+	}
+
+	links, err := findBrokenLinks(newMemoryStore(pages), normalizePolicy{Query: queryKeepAll})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []brokenLink{{Source: "example.com", Target: "https://example.com/list?page=2", Status: 500, Error: "error status code: 500"}}
+	if !reflect.DeepEqual(links, expected) {
+		t.Errorf("expected %+v, got %+v", expected, links)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// checkpointFile is the name of the checkpoint inside the state directory
+const checkpointFile = "checkpoint.json"
+
+// checkpoint is a consistent snapshot of a crawl that can be resumed later
+type checkpoint struct {
+	BaseURL   string              `json:"base_url"`
+	SavedAt   time.Time           `json:"saved_at"`
+	Pages     map[string]PageData `json:"pages,omitempty"`      // fully processed pages by normalized URL
+	PageStore string              `json:"page_store,omitempty"` // database file holding the pages instead of Pages
+	Frontier  []string            `json:"frontier"`             // raw URLs enqueued but not yet processed
+	Depths    map[string]int      `json:"depths,omitempty"`     // depth of frontier URLs not linked from the start page
+	Traps     *trapState          `json:"traps,omitempty"`      // URLs counted towards the trap limits
+	Learner   *learnerState       `json:"learner,omitempty"`    // evidence and parameters of the parameter learner
+}
+
+// snapshot captures the crawl state. Pages still being fetched are left out and
+// their URLs stay in the frontier, so resuming fetches them again. Pages and queued
+// URLs kept in a database file aren't copied; the checkpoint refers to the file instead.
+func (cfg *config) snapshot() checkpoint {
+	cp := checkpoint{
+		BaseURL:  cfg.baseURL.String(),
+		SavedAt:  time.Now().UTC(),
+		Frontier: []string{},
+	}
+	switch store := cfg.pages.(type) {
+	case *boltStore:
+		cp.PageStore = store.db.Path()
+	case *memoryStore:
+		var queue []queuedURL
+		cp.Pages, queue = store.snapshot()
+		for _, u := range queue {
+			cp.Frontier = append(cp.Frontier, u.URL)
+			if u.Depth > 0 {
+				if cp.Depths == nil {
+					cp.Depths = make(map[string]int)
+				}
+				cp.Depths[u.URL] = u.Depth
+			}
+		}
+	}
+	if cfg.traps != nil {
+		cp.Traps = cfg.traps.state()
+	}
+	if cfg.normalizer.Learner != nil {
+		cp.Learner = cfg.normalizer.Learner.state()
+	}
+	return cp
+}
+
+// saveCheckpoint writes the crawl state to dir, replacing any earlier checkpoint atomically
+func (cfg *config) saveCheckpoint(dir string) error {
+	if dir == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(cfg.snapshot())
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, checkpointFile+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, checkpointFile))
+}
+
+// loadCheckpoint reads the checkpoint saved in dir
+func loadCheckpoint(dir string) (checkpoint, error) {
+	data, err := os.ReadFile(filepath.Join(dir, checkpointFile))
+	if err != nil {
+		return checkpoint{}, err
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return checkpoint{}, fmt.Errorf("reading checkpoint: %w", err)
+	}
+	return cp, nil
+}
+
+// resume restores the pages and trap and learner state from a checkpoint and queues its frontier.
+// The checkpoint must come from a crawl of the same base URL.
+func (cfg *config) resume(cp checkpoint) error {
+	if cp.BaseURL != cfg.baseURL.String() {
+		return fmt.Errorf("checkpoint is for %s, not %s", cp.BaseURL, cfg.baseURL)
+	}
+	if cfg.traps != nil && cp.Traps != nil {
+		cfg.traps.restore(*cp.Traps)
+	}
+	if cfg.normalizer.Learner != nil && cp.Learner != nil {
+		cfg.normalizer.Learner.restore(*cp.Learner)
+	}
+
+	// A database keeps being written after its last checkpoint, and already holds
+	// every page and queued URL up to the moment the crawl stopped
+	if cp.PageStore != "" {
+		store, onDisk := cfg.pages.(*boltStore)
+		if !onDisk || store.db.Path() != cp.PageStore {
+			return fmt.Errorf("checkpoint pages are in %s; resume with -page-store %s", cp.PageStore, cp.PageStore)
+		}
+		return cfg.pages.each(func(_ string, pageData PageData) error {
+			if pageData.URL != "" {
+				cfg.fetched++
+			}
+			return nil
+		})
+	}
+
+	for pageURL, pageData := range cp.Pages {
+		if err := cfg.pages.put(pageURL, pageData); err != nil {
+			return err
+		}
+		if pageData.DuplicateOf == "" {
+			cfg.registerContent(pageURL, pageData.ContentHash)
+		}
+	}
+	cfg.fetched = len(cp.Pages)
+	for _, rawURL := range cp.Frontier {
+		if err := cfg.enqueue(rawURL, cp.Depths[rawURL]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkpointEvery saves a checkpoint to dir every interval until stop is closed
+func (cfg *config) checkpointEvery(dir string, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := cfg.saveCheckpoint(dir); err != nil {
+				fmt.Fprintf(os.Stderr, "error saving checkpoint: %v\n", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
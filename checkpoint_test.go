@@ -0,0 +1,288 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	baseURL, _ := url.Parse("https://example.com")
+	store := newMemoryStore(map[string]PageData{
+		"example.com":   {URL: "https://example.com", H1: "Home", OutgoingLinks: []string{"https://example.com/a"}},
+		"example.com/a": {URL: "https://example.com/a", H1: "A"},
+	})
+	// /fetch is being fetched and /b is waiting its turn
+	store.enqueue([]queuedURL{
+		{Key: "example.com/fetch", URL: "https://example.com/fetch"},
+		{Key: "example.com/b", URL: "https://example.com/b", Depth: 1},
+	})
+	cfg := &config{pages: store, baseURL: baseURL, mu: &sync.Mutex{}}
+
+	dir := t.TempDir()
+	if err := cfg.saveCheckpoint(dir); err != nil {
+		t.Fatalf("unexpected error saving: %v", err)
+	}
+	cp, err := loadCheckpoint(dir)
+	if err != nil {
+		t.Fatalf("unexpected error loading: %v", err)
+	}
+
+	// The page still being fetched is left for the resumed crawl to fetch again
+	expectedPages := map[string]PageData{
+		"example.com":   {URL: "https://example.com", H1: "Home", OutgoingLinks: []string{"https://example.com/a"}},
+		"example.com/a": {URL: "https://example.com/a", H1: "A"},
+	}
+	if !reflect.DeepEqual(cp.Pages, expectedPages) {
+		t.Errorf("expected pages %+v, got %+v", expectedPages, cp.Pages)
+	}
+	expectedFrontier := []string{"https://example.com/fetch", "https://example.com/b"}
+	if !reflect.DeepEqual(cp.Frontier, expectedFrontier) {
+		t.Errorf("expected frontier %v, got %v", expectedFrontier, cp.Frontier)
+	}
+	expectedDepths := map[string]int{"https://example.com/b": 1}
+	if !reflect.DeepEqual(cp.Depths, expectedDepths) {
+		t.Errorf("expected depths %v, got %v", expectedDepths, cp.Depths)
+	}
+	if cp.BaseURL != "https://example.com" {
+		t.Errorf("expected base URL https://example.com, got %q", cp.BaseURL)
+	}
+}
+
+func TestCheckpointKeepsTrapAndLearnerState(t *testing.T) {
+	baseURL, _ := url.Parse("https://example.com")
+	traps := newTrapDetector()
+	traps.MaxQueryVariants = 2
+	learner := newParamLearner()
+	learner.observe("https://example.com/?s=1", "hash-home")
+	learner.observe("https://example.com/?s=2", "hash-home")
+	learner.observe("https://example.com/list?page=1&v=1", "hash-list")
+	cfg := &config{
+		pages:      newMemoryStore(nil),
+		baseURL:    baseURL,
+		mu:         &sync.Mutex{},
+		traps:      traps,
+		normalizer: normalizePolicy{Query: queryKeepAll, Learner: learner},
+	}
+	cfg.enqueueLinks([]string{"https://example.com/cal?d=1", "https://example.com/cal?d=2", "https://example.com/cal?d=3"}, 1)
+
+	dir := t.TempDir()
+	if err := cfg.saveCheckpoint(dir); err != nil {
+		t.Fatalf("unexpected error saving: %v", err)
+	}
+	cp, err := loadCheckpoint(dir)
+	if err != nil {
+		t.Fatalf("unexpected error loading: %v", err)
+	}
+
+	resumedTraps := newTrapDetector()
+	resumedTraps.MaxQueryVariants = 2
+	resumed := &config{
+		pages:              newMemoryStore(nil),
+		baseURL:            baseURL,
+		mu:                 &sync.Mutex{},
+		wg:                 &sync.WaitGroup{},
+		concurrencyControl: make(chan struct{}, 1),
+		traps:              resumedTraps,
+		normalizer:         normalizePolicy{Query: queryKeepAll, Learner: newParamLearner()},
+	}
+	if err := resumed.resume(cp); err != nil {
+		t.Fatalf("unexpected error resuming: %v", err)
+	}
+
+	// The two counted variants still fill the limit, and the third is still reported
+	resumed.enqueueLinks([]string{"https://example.com/cal?d=4"}, 1)
+	if traps := resumedTraps.suspectedTraps(); len(traps) != 2 || traps[0].URL != "https://example.com/cal?d=3" || traps[1].URL != "https://example.com/cal?d=4" {
+		t.Errorf("expected the resumed detector to remember the counted query variants, got %+v", traps)
+	}
+
+	// What was learned still applies, and earlier evidence still counts
+	resumedLearner := resumed.normalizer.Learner
+	if !resumedLearner.isIgnored("example.com/", "s") {
+		t.Error("expected the learned parameter to survive the checkpoint")
+	}
+	resumedLearner.observe("https://example.com/list?page=1&v=2", "hash-list")
+	if !resumedLearner.isIgnored("example.com/list", "v") {
+		t.Error("expected evidence from before the checkpoint to count after resuming")
+	}
+}
+
+func TestResumeRejectsOtherSite(t *testing.T) {
+	baseURL, _ := url.Parse("https://example.com")
+	cfg := &config{pages: newMemoryStore(nil), baseURL: baseURL, mu: &sync.Mutex{}, wg: &sync.WaitGroup{}}
+
+	if err := cfg.resume(checkpoint{BaseURL: "https://other.example"}); err == nil {
+		t.Error("expected an error resuming a checkpoint for a different site")
+	}
+}
+
+func TestResumeAfterKilledCrawl(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		fetches = make(map[string]int)
+		resumed bool
+	)
+	release := make(chan struct{})
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slow := r.URL.Path == "/page3" || r.URL.Path == "/page4"
+		mu.Lock()
+		blocked := slow && !resumed
+		mu.Unlock()
+		if blocked {
+			// Hang until the first crawl is "killed", then fail so it stores nothing
+			<-release
+			http.Error(w, "gone", http.StatusServiceUnavailable)
+			return
+		}
+
+		mu.Lock()
+		fetches[r.URL.Path]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><h1>` + r.URL.Path + `</h1>
+			<a href="/page1">1</a><a href="/page2">2</a><a href="/page3">3</a><a href="/page4">4</a>
+		</body></html>`))
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	baseURL, _ := url.Parse(server.URL)
+	newConfig := func() *config {
+		return &config{
+			pages:              newMemoryStore(nil),
+			baseURL:            baseURL,
+			mu:                 &sync.Mutex{},
+			concurrencyControl: make(chan struct{}, 5),
+			wg:                 &sync.WaitGroup{},
+		}
+	}
+
+	// First crawl gets through the home page, page1 and page2, then hangs on page3 and page4
+	first := newConfig()
+	first.enqueue(server.URL, 0)
+	go first.crawl()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		cp := first.snapshot()
+		if len(cp.Pages) == 3 && len(cp.Frontier) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("crawl never reached the expected midway state: %d pages, frontier %v", len(cp.Pages), cp.Frontier)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	dir := t.TempDir()
+	if err := first.saveCheckpoint(dir); err != nil {
+		t.Fatalf("unexpected error saving checkpoint: %v", err)
+	}
+
+	// Kill the first crawl: its hung requests fail and it is never looked at again
+	mu.Lock()
+	resumed = true
+	mu.Unlock()
+	close(release)
+
+	cp, err := loadCheckpoint(dir)
+	if err != nil {
+		t.Fatalf("unexpected error loading checkpoint: %v", err)
+	}
+	second := newConfig()
+	if err := second.resume(cp); err != nil {
+		t.Fatalf("unexpected error resuming: %v", err)
+	}
+	if err := second.crawl(); err != nil {
+		t.Fatalf("unexpected error crawling: %v", err)
+	}
+
+	if second.pages.count() != 5 {
+		t.Fatalf("expected 5 pages after resuming, got %d", second.pages.count())
+	}
+	second.pages.each(func(pageURL string, pageData PageData) error {
+		if pageData.H1 == "" {
+			t.Errorf("expected %s to have been crawled, got %+v", pageURL, pageData)
+		}
+		return nil
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	expectedFetches := map[string]int{"/": 1, "/page1": 1, "/page2": 1, "/page3": 1, "/page4": 1}
+	if !reflect.DeepEqual(fetches, expectedFetches) {
+		t.Errorf("expected every page fetched exactly once across both crawls, got %v", fetches)
+	}
+}
+
+func TestResumeFromPageStore(t *testing.T) {
+	var mu sync.Mutex
+	fetches := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches[r.URL.Path]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><h1>` + r.URL.Path + `</h1></body></html>`))
+	}))
+	defer server.Close()
+
+	baseURL, _ := url.Parse(server.URL)
+	host := baseURL.Host
+	path := filepath.Join(t.TempDir(), "pages.db")
+
+	// The killed crawl stored the home page and /a, was still fetching /b, and hadn't reached /c,
+	// which /a links to. It stopped before taking /a off the queue, and its last checkpoint
+	// predates all of that.
+	store, err := openBoltStore(path, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	store.enqueue([]queuedURL{{Key: host, URL: server.URL}})
+	store.enqueue([]queuedURL{{Key: host + "/a", URL: server.URL + "/a", Depth: 1}, {Key: host + "/b", URL: server.URL + "/b", Depth: 1}})
+	store.put(host, PageData{URL: server.URL, H1: "/", OutgoingLinks: []string{server.URL + "/a", server.URL + "/b"}})
+	queue, _ := store.queued(0, 1)
+	store.dequeue(queue[0].seq)
+	store.enqueue([]queuedURL{{Key: host + "/c", URL: server.URL + "/c", Depth: 2}})
+	store.put(host+"/a", PageData{URL: server.URL + "/a", H1: "/a", OutgoingLinks: []string{server.URL + "/c"}})
+	store.close()
+
+	store, err = openBoltStore(path, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer store.close()
+	cfg := &config{
+		pages:              store,
+		baseURL:            baseURL,
+		mu:                 &sync.Mutex{},
+		concurrencyControl: make(chan struct{}, 5),
+		wg:                 &sync.WaitGroup{},
+	}
+	if err := cfg.resume(checkpoint{BaseURL: server.URL, PageStore: path}); err != nil {
+		t.Fatalf("unexpected error resuming: %v", err)
+	}
+	if err := cfg.crawl(); err != nil {
+		t.Fatalf("unexpected error crawling: %v", err)
+	}
+	if store.queueLen() != 0 {
+		t.Errorf("expected an empty queue after the crawl, got %d URLs", store.queueLen())
+	}
+
+	for _, pageURL := range []string{host, host + "/a", host + "/b", host + "/c"} {
+		if pageData, ok, _ := store.get(pageURL); !ok || pageData.H1 == "" {
+			t.Errorf("expected %s to be crawled, got %+v", pageURL, pageData)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	expectedFetches := map[string]int{"/b": 1, "/c": 1}
+	if !reflect.DeepEqual(fetches, expectedFetches) {
+		t.Errorf("expected only unfinished pages to be fetched, got %v", fetches)
+	}
+}
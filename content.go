@@ -0,0 +1,256 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// wordsPerMinute is the reading speed used to estimate reading time
+const wordsPerMinute = 200
+
+// MainContent is the readable text of a page with navigation and other boilerplate removed
+type MainContent struct {
+	Text           string
+	WordCount      int
+	ReadingMinutes int
+}
+
+// boilerplateTags are elements whose content is never part of the main text
+var boilerplateTags = map[string]bool{
+	"nav":      true,
+	"header":   true,
+	"footer":   true,
+	"aside":    true,
+	"script":   true,
+	"style":    true,
+	"noscript": true,
+	"template": true,
+	"iframe":   true,
+	"svg":      true,
+}
+
+// boilerplateRoles are ARIA landmark roles equivalent to the boilerplate tags
+var boilerplateRoles = map[string]bool{
+	"navigation":    true,
+	"banner":        true,
+	"contentinfo":   true,
+	"complementary": true,
+	"search":        true,
+	"dialog":        true,
+	"alertdialog":   true,
+}
+
+// boilerplateHints are id/class substrings used by cookie banners and consent popups
+var boilerplateHints = []string{"cookie", "consent", "gdpr"}
+
+// maxHintedWords is the most text an element matching a boilerplate hint can hold and still
+// be dropped; longer ones are page wrappers like class="has-cookie-banner", not the banner
+const maxHintedWords = 150
+
+// maxFormWords is the most text a form can hold and still be dropped as a search box, login or
+// signup form; larger ones are page wrappers
+const maxFormWords = 150
+
+// hintExemptTags are page-level elements never dropped for their id or class
+var hintExemptTags = map[string]bool{"html": true, "body": true, "main": true, "article": true}
+
+// blockTags start a new line in extracted text
+var blockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"li": true, "ul": true, "ol": true, "dl": true, "dt": true, "dd": true,
+	"table": true, "tr": true, "td": true, "th": true,
+	"blockquote": true, "pre": true, "figure": true, "figcaption": true, "br": true, "hr": true,
+}
+
+// extractMainContent returns the page's main text, word count and estimated reading time
+func extractMainContent(htmlBody string) MainContent {
+	doc, err := html.Parse(strings.NewReader(htmlBody))
+	if err != nil {
+		return MainContent{}
+	}
+	return extractMainContentFromDoc(doc)
+}
+
+// extractMainContentFromDoc is extractMainContent for an already parsed page
+func extractMainContentFromDoc(doc *html.Node) MainContent {
+	root := findContentRoot(doc)
+	if root == nil {
+		return MainContent{}
+	}
+
+	var raw strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if isBoilerplate(n) {
+			return
+		}
+		if n.Type == html.TextNode {
+			// Line breaks in the source are just whitespace; only block elements start new lines
+			raw.WriteString(strings.ReplaceAll(n.Data, "\n", " "))
+			return
+		}
+		block := n.Type == html.ElementNode && blockTags[n.Data]
+		if block {
+			raw.WriteString("\n")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if block {
+			raw.WriteString("\n")
+		}
+	}
+	walk(root)
+
+	var lines []string
+	wordCount := 0
+	for _, line := range strings.Split(raw.String(), "\n") {
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			continue
+		}
+		wordCount += len(words)
+		lines = append(lines, strings.Join(words, " "))
+	}
+
+	return MainContent{
+		Text:           strings.Join(lines, "\n"),
+		WordCount:      wordCount,
+		ReadingMinutes: (wordCount + wordsPerMinute - 1) / wordsPerMinute,
+	}
+}
+
+// contentFingerprint hashes text with case and whitespace normalized, so pages whose
+// markup differs only in formatting get the same fingerprint. Pages without text, such as
+// image-only or script-rendered ones, get "" since they can't be told apart.
+func contentFingerprint(text string) string {
+	normalized := strings.ToLower(strings.Join(strings.Fields(text), " "))
+	if normalized == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// findContentRoot picks the element holding the main content: <main>, a single <article>, or <body>
+func findContentRoot(doc *html.Node) *html.Node {
+	if mainNode := findNodeFunc(doc, func(n *html.Node) bool {
+		return n.Data == "main" || getAttr(n, "role") == "main"
+	}); mainNode != nil {
+		return mainNode
+	}
+
+	var articles []*html.Node
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if isBoilerplate(n) {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "article" {
+			articles = append(articles, n)
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(doc)
+	if len(articles) == 1 {
+		return articles[0]
+	}
+
+	if body := findNode(doc, "body"); body != nil {
+		return body
+	}
+	return doc
+}
+
+// findNodeFunc finds the first element node matching the predicate
+func findNodeFunc(n *html.Node, match func(*html.Node) bool) *html.Node {
+	if n.Type == html.ElementNode && match(n) {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if result := findNodeFunc(c, match); result != nil {
+			return result
+		}
+	}
+	return nil
+}
+
+// findContentNode finds the first node with the given tag name outside of boilerplate elements
+func findContentNode(n *html.Node, tag string) *html.Node {
+	if isBoilerplate(n) {
+		return nil
+	}
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if result := findContentNode(c, tag); result != nil {
+			return result
+		}
+	}
+	return nil
+}
+
+// isBoilerplate reports whether an element is navigation, chrome, scripting or a consent banner
+func isBoilerplate(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if boilerplateTags[n.Data] || boilerplateRoles[getAttr(n, "role")] {
+		return true
+	}
+	if getAttr(n, "aria-hidden") == "true" {
+		return true
+	}
+	if n.Data == "form" {
+		return !isPageForm(n)
+	}
+	if hintExemptTags[n.Data] {
+		return false
+	}
+	hints := strings.ToLower(getAttr(n, "id") + " " + getAttr(n, "class"))
+	for _, hint := range boilerplateHints {
+		if strings.Contains(hints, hint) {
+			return countWords(n, maxHintedWords+1) <= maxHintedWords
+		}
+	}
+	return false
+}
+
+// isPageForm reports whether a form wraps the page rather than being part of it, as the
+// <form runat="server"> of ASP.NET WebForms pages does
+func isPageForm(n *html.Node) bool {
+	if n.Parent != nil && n.Parent.Type == html.ElementNode && n.Parent.Data == "body" {
+		return true
+	}
+	return countWords(n, maxFormWords+1) > maxFormWords
+}
+
+// countWords counts the words of text under n, stopping once limit is reached
+func countWords(n *html.Node, limit int) int {
+	if n.Type == html.TextNode {
+		return len(strings.Fields(n.Data))
+	}
+	count := 0
+	for c := n.FirstChild; c != nil && count < limit; c = c.NextSibling {
+		count += countWords(c, limit-count)
+	}
+	return count
+}
+
+// getAttr returns the value of the named attribute, or "" if absent
+func getAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExtractMainContentStripsBoilerplate(t *testing.T) {
+	inputBody := `<html><head><style>body { color: red; }</style></head><body>
+		<header><a href="/">Home</a></header>
+		<nav><ul><li>Blog</li><li>About</li></ul></nav>
+		<div class="cookie-consent"><p>We use cookies to improve your experience.</p></div>
+		<h1>Article Title</h1>
+		<p>First   paragraph of the
+			article.</p>
+		<p>Second paragraph.</p>
+		<script>console.log("tracking")</script>
+		<aside>Related posts</aside>
+		<footer>Copyright</footer>
+	</body></html>`
+
+	actual := extractMainContent(inputBody)
+
+	expectedText := "Article Title\nFirst paragraph of the article.\nSecond paragraph."
+	if actual.Text != expectedText {
+		t.Errorf("expected text %q, got %q", expectedText, actual.Text)
+	}
+	if actual.WordCount != 9 {
+		t.Errorf("expected 9 words, got %d", actual.WordCount)
+	}
+	if actual.ReadingMinutes != 1 {
+		t.Errorf("expected 1 minute reading time, got %d", actual.ReadingMinutes)
+	}
+}
+
+func TestExtractMainContentKeepsConsentWrappers(t *testing.T) {
+	article := strings.Repeat("Long article text about the topic. ", 40)
+	tests := []struct {
+		name      string
+		inputBody string
+		expected  string
+	}{
+		{
+			name:      "hinted body",
+			inputBody: `<html class="gdpr"><body class="has-cookie-banner"><p>Page text.</p></body></html>`,
+			expected:  "Page text.",
+		},
+		{
+			name:      "hinted main",
+			inputBody: `<html><body><main id="consent-managed"><p>Page text.</p></main></body></html>`,
+			expected:  "Page text.",
+		},
+		{
+			name:      "large hinted wrapper",
+			inputBody: `<html><body><div class="cookie-wrapper"><p>` + article + `</p><div class="cookie-banner">Accept cookies?</div></div></body></html>`,
+			expected:  strings.TrimSpace(article),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := extractMainContent(tc.inputBody).Text; actual != tc.expected {
+				t.Errorf("expected text %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestExtractMainContentForms(t *testing.T) {
+	article := strings.Repeat("Long article text about the topic. ", 40)
+	tests := []struct {
+		name      string
+		inputBody string
+		expected  string
+	}{
+		{
+			name:      "search form",
+			inputBody: `<html><body><div><form action="/search"><label>Search the site</label><input name="q"></form></div><p>Page text.</p></body></html>`,
+			expected:  "Page text.",
+		},
+		{
+			name:      "newsletter form",
+			inputBody: `<html><body><p>Page text.</p><div class="signup"><form><p>Get our newsletter</p><input name="email"></form></div></body></html>`,
+			expected:  "Page text.",
+		},
+		{
+			name:      "large form",
+			inputBody: `<html><body><div><form><p>` + article + `</p></form></div></body></html>`,
+			expected:  strings.TrimSpace(article),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := extractMainContent(tc.inputBody).Text; actual != tc.expected {
+				t.Errorf("expected text %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestExtractPageDataWebFormsPage(t *testing.T) {
+	inputBody := `<html><head><title>Products</title></head><body>
+		<form method="post" action="./products.aspx" id="form1">
+			<input type="hidden" name="__VIEWSTATE" value="dDwtMTA4MzE0MjEwNTs7Pg==">
+			<div id="header"><a href="/">Home</a></div>
+			<h1>Our Products</h1>
+			<p>We make widgets for every occasion.</p>
+			<input type="submit" name="btnSearch" value="Search">
+		</form>
+	</body></html>`
+
+	actual := extractPageData(inputBody, "https://example.com/products.aspx")
+
+	if actual.WordCount == 0 {
+		t.Errorf("expected words, got none")
+	}
+	if !strings.Contains(actual.FirstParagraph, "widgets") {
+		t.Errorf("expected the first paragraph, got %q", actual.FirstParagraph)
+	}
+	if actual.ContentHash == "" {
+		t.Errorf("expected a content hash")
+	}
+	if content := extractMainContent(inputBody); !strings.Contains(content.Text, "We make widgets for every occasion.") {
+		t.Errorf("expected the page text, got %q", content.Text)
+	}
+}
+
+func TestExtractMainContentPrefersMain(t *testing.T) {
+	inputBody := `<html><body>
+		<div>Sidebar promo text</div>
+		<main><p>Main text.</p></main>
+	</body></html>`
+
+	actual := extractMainContent(inputBody)
+	if actual.Text != "Main text." {
+		t.Errorf("expected %q, got %q", "Main text.", actual.Text)
+	}
+}
+
+func TestExtractMainContentPrefersSingleArticle(t *testing.T) {
+	inputBody := `<html><body>
+		<div>Sidebar promo text</div>
+		<article><p>Article text.</p></article>
+	</body></html>`
+
+	actual := extractMainContent(inputBody)
+	if actual.Text != "Article text." {
+		t.Errorf("expected %q, got %q", "Article text.", actual.Text)
+	}
+}
+
+func TestExtractMainContentReadingTime(t *testing.T) {
+	words := ""
+	for i := 0; i < 450; i++ {
+		words += "word "
+	}
+
+	actual := extractMainContent("<html><body><p>" + words + "</p></body></html>")
+	if actual.WordCount != 450 {
+		t.Errorf("expected 450 words, got %d", actual.WordCount)
+	}
+	if actual.ReadingMinutes != 3 {
+		t.Errorf("expected 3 minutes at %d wpm, got %d", wordsPerMinute, actual.ReadingMinutes)
+	}
+}
+
+func TestExtractMainContentEmptyBody(t *testing.T) {
+	actual := extractMainContent("")
+	if actual.Text != "" || actual.WordCount != 0 || actual.ReadingMinutes != 0 {
+		t.Errorf("expected empty content, got %+v", actual)
+	}
+}
+
+func TestContentFingerprint(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  string
+		equal bool
+	}{
+		{name: "formatting differences", a: "Hello   World\n", b: "hello world", equal: true},
+		{name: "different text", a: "Hello", b: "Goodbye", equal: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if equal := contentFingerprint(tc.a) == contentFingerprint(tc.b); equal != tc.equal {
+				t.Errorf("expected fingerprints equal = %v, got %v", tc.equal, equal)
+			}
+		})
+	}
+
+	for _, empty := range []string{"", "  \n\t "} {
+		if fingerprint := contentFingerprint(empty); fingerprint != "" {
+			t.Errorf("expected no fingerprint for %q, got %s", empty, fingerprint)
+		}
+	}
+}
@@ -1,90 +1,315 @@
 package main
 
 import (
+	"fmt"
 	"net/url"
 	"sync"
+	"time"
 )
 
+// queueBatch is how many queued URLs are read from the page store at a time
+const queueBatch = 256
+
 type config struct {
-	pages              map[string]PageData
+	pages              pageStore
 	baseURL            *url.URL
 	mu                 *sync.Mutex
 	concurrencyControl chan struct{}
 	wg                 *sync.WaitGroup
 	maxPages           int
+	maxDepth           int           // links to follow from the start page; 0 = no limit
+	assetChecker       *assetChecker // nil skips HEAD requests for assets
+	fetchOpts          fetchOptions
+	normalizer         normalizePolicy
+	traps              *trapDetector // nil crawls every same-host link
+	skipDuplicateLinks bool          // don't follow links from pages whose content was already crawled
+	previous           pageStore     // an earlier crawl to revalidate pages against; nil fetches every page in full
+	stream             *pageStream   // nil writes reports only after the crawl
+	fetched            int           // pages fetched or being fetched, counted against maxPages
 }
 
-// addPageVisit checks if a page has been visited and adds it if not
-func (cfg *config) addPageVisit(normalizedURL string) (isFirst bool) {
-	cfg.mu.Lock()
-	defer cfg.mu.Unlock()
+// enqueue queues rawURL, found depth links away from the start page, unless it is on
+// another host or was already queued
+func (cfg *config) enqueue(rawURL string, depth int) error {
+	u, ok := cfg.queueEntry(rawURL, depth)
+	if !ok {
+		return nil
+	}
+	_, err := cfg.pages.enqueue([]queuedURL{u})
+	return err
+}
 
-	if _, exists := cfg.pages[normalizedURL]; exists {
-		return false
+// enqueueLinks queues the links of a page found depth links away from the start page,
+// leaving out links on other hosts, links already queued and suspected traps
+func (cfg *config) enqueueLinks(links []string, depth int) error {
+	var candidates []queuedURL
+	var parsed []*url.URL
+	for _, link := range links {
+		u, ok := cfg.queueEntry(link, depth)
+		if !ok {
+			continue
+		}
+		if cfg.traps != nil {
+			// Only URLs found for the first time count towards the trap limits
+			if _, known, err := cfg.pages.get(u.Key); err != nil || known {
+				continue
+			}
+			parsedURL, _ := url.Parse(link)
+			if cfg.traps.check(parsedURL, u.Key) != "" {
+				continue
+			}
+			parsed = append(parsed, parsedURL)
+		}
+		candidates = append(candidates, u)
+	}
+	if len(candidates) == 0 {
+		return nil
 	}
 
-	cfg.pages[normalizedURL] = PageData{}
-	return true
+	added, err := cfg.pages.enqueue(candidates)
+	if err != nil {
+		return err
+	}
+	if cfg.traps != nil {
+		// Another page queued these first, or they appear twice in this page
+		for i, isNew := range added {
+			if !isNew {
+				cfg.traps.release(parsed[i], candidates[i].Key)
+			}
+		}
+	}
+	return nil
 }
 
-// pagesLen returns the current number of pages (thread-safe)
-func (cfg *config) pagesLen() int {
-	cfg.mu.Lock()
-	defer cfg.mu.Unlock()
-	return len(cfg.pages)
+// queueEntry normalizes a same-host URL for the queue
+func (cfg *config) queueEntry(rawURL string, depth int) (queuedURL, bool) {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || !sameHost(cfg.baseURL, parsedURL) {
+		return queuedURL{}, false
+	}
+	normalizedURL, err := cfg.normalizer.normalize(rawURL)
+	if err != nil {
+		return queuedURL{}, false
+	}
+	return queuedURL{Key: normalizedURL, URL: rawURL, Depth: depth}, true
 }
 
-// crawlPage recursively crawls pages starting from rawCurrentURL
-func (cfg *config) crawlPage(rawCurrentURL string) {
-	// Acquire a slot from the concurrency control channel
-	cfg.concurrencyControl <- struct{}{}
-	defer func() {
-		<-cfg.concurrencyControl
-		cfg.wg.Done()
-	}()
+// crawl crawls queued URLs, cap(concurrencyControl) at a time, until the queue is empty,
+// then settles the keys of pages stored before the parameter learner knew to ignore one
+// of their parameters. Only a batch of the queue is held in memory at a time. Pages are
+// crawled breadth-first, one depth at a time, so each page is first found, and given its
+// depth, by a shortest path from the start page. The crawl stops at the first page that
+// can't be saved to the page store.
+func (cfg *config) crawl() error {
+	var (
+		mu       sync.Mutex
+		progress = sync.NewCond(&mu)
+		inFlight int
+		finished int   // pages done, so an empty read of the queue can tell if it went stale
+		storeErr error // first page store failure of a page's goroutine
+	)
+	var next uint64
+	level := 0
+	for {
+		mu.Lock()
+		finishedBefore, err := finished, storeErr
+		mu.Unlock()
+		if err != nil {
+			cfg.wg.Wait()
+			return err
+		}
+
+		batch, err := cfg.pages.queued(next, queueBatch)
+		if err != nil {
+			cfg.wg.Wait()
+			return err
+		}
+		if len(batch) == 0 {
+			// Only pages being crawled can queue more URLs
+			mu.Lock()
+			for inFlight > 0 && finished == finishedBefore {
+				progress.Wait()
+			}
+			done := inFlight == 0 && finished == finishedBefore
+			mu.Unlock()
+			if done {
+				break
+			}
+			continue
+		}
+
+		for _, u := range batch {
+			mu.Lock()
+			failed := storeErr != nil
+			mu.Unlock()
+			if failed {
+				break
+			}
+
+			// The queue is in depth order, so a deeper URL means the current level is all
+			// dispatched; finishing it first lets it queue every URL of the next level
+			if u.Depth > level {
+				mu.Lock()
+				for inFlight > 0 {
+					progress.Wait()
+				}
+				mu.Unlock()
+				level = u.Depth
+			}
+
+			cfg.concurrencyControl <- struct{}{}
+			mu.Lock()
+			inFlight++
+			mu.Unlock()
+			cfg.wg.Add(1)
+			go func() {
+				var err error
+				defer func() {
+					<-cfg.concurrencyControl
+					mu.Lock()
+					inFlight--
+					finished++
+					if err != nil && storeErr == nil {
+						storeErr = err
+					}
+					progress.Broadcast()
+					mu.Unlock()
+					cfg.wg.Done()
+				}()
+				if err = cfg.crawlPage(u); err == nil {
+					err = cfg.pages.dequeue(u.seq)
+				}
+			}()
+			next = u.seq + 1
+		}
+	}
+	cfg.wg.Wait()
+	if storeErr != nil {
+		return storeErr
+	}
+	return cfg.rekeyLearnedPages()
+}
 
-	// Check if we've reached the max pages limit
-	if cfg.maxPages > 0 && cfg.pagesLen() >= cfg.maxPages {
-		return
+// claimFetch counts a page against maxPages, reporting false once the limit is reached
+func (cfg *config) claimFetch() bool {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	if cfg.maxPages > 0 && cfg.fetched >= cfg.maxPages {
+		return false
 	}
+	cfg.fetched++
+	return true
+}
 
-	currentURL, err := url.Parse(rawCurrentURL)
-	if err != nil {
-		return
+// storePage saves a finished page and sends it to the stream, if any
+func (cfg *config) storePage(normalizedURL string, pageData PageData) error {
+	if err := cfg.pages.put(normalizedURL, pageData); err != nil {
+		return err
+	}
+	if cfg.stream != nil {
+		cfg.stream.send(normalizedURL, pageData)
 	}
+	return nil
+}
 
-	// Only crawl pages on the same domain
-	if cfg.baseURL.Host != currentURL.Host {
-		return
+// previousPage returns the earlier crawl's data for a page, if it was fetched successfully
+func (cfg *config) previousPage(normalizedURL string) (PageData, bool) {
+	if cfg.previous == nil {
+		return PageData{}, false
+	}
+	pageData, ok, err := cfg.previous.get(normalizedURL)
+	if err != nil || !ok || pageData.URL == "" || pageData.FetchError != "" {
+		return PageData{}, false
 	}
+	return pageData, true
+}
 
-	// Normalize the current URL
-	normalizedURL, err := normalizeURL(rawCurrentURL)
-	if err != nil {
-		return
+// crawlPage fetches a queued page, queues its links and stores it. Links are queued
+// before the page is stored, so a stored page's links are never lost if the crawl stops.
+// Fetch failures are stored with the page; the error returned is a page store failure.
+func (cfg *config) crawlPage(u queuedURL) error {
+	rawCurrentURL, normalizedURL, depth := u.URL, u.Key, u.Depth
+
+	// A resumed crawl may find pages that finished just before it stopped
+	stored, _, err := cfg.pages.get(normalizedURL)
+	if err != nil || stored.URL != "" {
+		return err
 	}
 
-	// If we've already visited this page, return
-	isFirst := cfg.addPageVisit(normalizedURL)
-	if !isFirst {
-		return
+	// Past the max pages limit, forget the page instead of leaving an empty placeholder
+	if !cfg.claimFetch() {
+		return cfg.pages.remove(normalizedURL)
 	}
 
-	// Fetch the HTML
-	html, err := getHTML(rawCurrentURL)
+	// Fetch the HTML, conditionally if an earlier crawl saw the page
+	opts := cfg.fetchOpts
+	previous, hasPrevious := cfg.previousPage(normalizedURL)
+	if hasPrevious {
+		opts.ETag, opts.LastModified = previous.ETag, previous.LastModified
+	}
+	fetchedAt := time.Now()
+	result, err := fetchPage(rawCurrentURL, opts)
+	responseTime := time.Since(fetchedAt)
+	if err == nil && result.NotModified && !hasPrevious {
+		// Without an earlier copy to reuse, a 304 leaves nothing to store
+		err = fmt.Errorf("unexpected status code: %d", result.StatusCode)
+	}
 	if err != nil {
-		return
+		return cfg.storePage(normalizedURL, PageData{
+			URL:          rawCurrentURL,
+			StatusCode:   result.StatusCode,
+			FetchError:   err.Error(),
+			Depth:        depth,
+			FetchedAt:    fetchedAt,
+			ResponseTime: responseTime,
+		})
 	}
 
-	// Extract page data and update the map
-	pageData := extractPageData(html, rawCurrentURL)
-	cfg.mu.Lock()
-	cfg.pages[normalizedURL] = pageData
-	cfg.mu.Unlock()
+	// Extract page data, or reuse the earlier crawl's if the page is unchanged
+	var pageData PageData
+	if result.NotModified {
+		pageData = previous
+		pageData.DuplicateOf = ""
+	} else {
+		// Relative links resolve against the URL the body came from
+		base := rawCurrentURL
+		if result.FinalURL != "" {
+			base = result.FinalURL
+		}
+		pageData = extractPageData(result.Body, base)
+		pageData.URL = rawCurrentURL
+		pageData.RedirectedTo = result.FinalURL
+		pageData.Charset = result.Charset
+		pageData.DeclaredContentType = result.DeclaredType
+		pageData.SniffedContentType = result.SniffedType
+		pageData.StatusCode = result.StatusCode
+		if hasNoIndex(result.RobotsTag) {
+			pageData.NoIndex = true
+		}
+	}
+	pageData.ETag = result.ETag
+	pageData.LastModified = result.LastModified
+	pageData.NotModified = result.NotModified
+	pageData.Depth = depth
+	pageData.FetchedAt = fetchedAt
+	pageData.ResponseTime = responseTime
+	pageData.Bytes = result.Bytes
+	if cfg.normalizer.Learner != nil {
+		cfg.normalizer.Learner.observe(rawCurrentURL, pageData.ContentHash)
+	}
+	if original := cfg.registerContent(normalizedURL, pageData.ContentHash); original != normalizedURL {
+		pageData.DuplicateOf = original
+	}
+	if cfg.assetChecker != nil && !pageData.NotModified {
+		pageData.Assets = cfg.assetChecker.check(pageData.Assets)
+	}
 
-	// Crawl each link concurrently
-	for _, link := range pageData.OutgoingLinks {
-		cfg.wg.Add(1)
-		go cfg.crawlPage(link)
+	followLinks := !(pageData.DuplicateOf != "" && cfg.skipDuplicateLinks) && !(cfg.maxDepth > 0 && depth >= cfg.maxDepth)
+	if followLinks {
+		if err := cfg.enqueueLinks(pageData.OutgoingLinks, depth+1); err != nil {
+			// Store the page anyway, so it doesn't stay behind as an empty placeholder
+			pageData.FetchError = "queueing links: " + err.Error()
+		}
 	}
+	return cfg.storePage(normalizedURL, pageData)
 }
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -73,8 +76,9 @@ func createTestServer(delay time.Duration) *httptest.Server {
 func runCrawlWithConcurrency(serverURL string, maxConcurrency int) (map[string]PageData, time.Duration) {
 	baseURL, _ := url.Parse(serverURL)
 
+	store := newMemoryStore(nil)
 	cfg := &config{
-		pages:              make(map[string]PageData),
+		pages:              store,
 		baseURL:            baseURL,
 		mu:                 &sync.Mutex{},
 		concurrencyControl: make(chan struct{}, maxConcurrency),
@@ -82,12 +86,11 @@ func runCrawlWithConcurrency(serverURL string, maxConcurrency int) (map[string]P
 	}
 
 	start := time.Now()
-	cfg.wg.Add(1)
-	go cfg.crawlPage(serverURL)
-	cfg.wg.Wait()
+	cfg.enqueue(serverURL, 0)
+	cfg.crawl()
 	elapsed := time.Since(start)
 
-	return cfg.pages, elapsed
+	return store.pages, elapsed
 }
 
 func TestCrawlConcurrency1(t *testing.T) {
@@ -204,3 +207,315 @@ func TestCrawlPerformanceComparison(t *testing.T) {
 		t.Logf("Warning: Concurrency 5 (%v) not faster than concurrency 2 (%v)", elapsed5, elapsed2)
 	}
 }
+
+func TestIncrementalCrawlReusesUnchangedPages(t *testing.T) {
+	var mu sync.Mutex
+	version := map[string]string{"/": "1", "/docs": "1", "/changelog": "1"}
+	fullResponses := make(map[string]int)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		etag := `"` + version[r.URL.Path] + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fullResponses[r.URL.Path]++
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("ETag", etag)
+		fmt.Fprintf(w, `<html><body><h1>%s v%s</h1><a href="/docs">Docs</a><a href="/changelog">Changes</a></body></html>`, r.URL.Path, version[r.URL.Path])
+	}))
+	defer server.Close()
+
+	crawl := func(previous pageStore) *memoryStore {
+		baseURL, _ := url.Parse(server.URL)
+		store := newMemoryStore(nil)
+		cfg := &config{
+			pages:              store,
+			baseURL:            baseURL,
+			mu:                 &sync.Mutex{},
+			concurrencyControl: make(chan struct{}, 3),
+			wg:                 &sync.WaitGroup{},
+			previous:           previous,
+		}
+		cfg.enqueue(server.URL, 0)
+		if err := cfg.crawl(); err != nil {
+			t.Fatal(err)
+		}
+		return store
+	}
+
+	first := crawl(nil)
+
+	mu.Lock()
+	version["/changelog"] = "2"
+	mu.Unlock()
+
+	second := crawl(first)
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	docs := second.pages[host+"/docs"]
+	if !docs.NotModified || docs.H1 != "/docs v1" || len(docs.OutgoingLinks) != 2 {
+		t.Errorf("expected /docs to reuse the previous crawl's data, got %+v", docs)
+	}
+	changelog := second.pages[host+"/changelog"]
+	if changelog.NotModified || changelog.H1 != "/changelog v2" || changelog.ETag != `"2"` {
+		t.Errorf("expected /changelog to be refetched, got %+v", changelog)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := map[string]int{"/": 1, "/docs": 1, "/changelog": 2}
+	for path, count := range expected {
+		if fullResponses[path] != count {
+			t.Errorf("expected %d full responses for %s, got %d", count, path, fullResponses[path])
+		}
+	}
+}
+
+func TestCrawlRecordsFailedPages(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><a href="/missing">Missing</a></body></html>`))
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	baseURL, _ := url.Parse(server.URL)
+	store := newMemoryStore(nil)
+	cfg := &config{
+		pages:              store,
+		baseURL:            baseURL,
+		mu:                 &sync.Mutex{},
+		concurrencyControl: make(chan struct{}, 2),
+		wg:                 &sync.WaitGroup{},
+	}
+	cfg.enqueue(server.URL, 0)
+	if err := cfg.crawl(); err != nil {
+		t.Fatal(err)
+	}
+
+	host := baseURL.Host
+	if home := store.pages[host]; home.StatusCode != 200 || home.FetchError != "" {
+		t.Errorf("expected home page to succeed, got status %d, error %q", home.StatusCode, home.FetchError)
+	}
+	missing := store.pages[host+"/missing"]
+	if missing.URL != server.URL+"/missing" || missing.StatusCode != 404 || !strings.Contains(missing.FetchError, "404") {
+		t.Errorf("expected /missing to be recorded as a 404, got %+v", missing)
+	}
+}
+
+func TestCrawlRecordsUnexpectedNotModified(t *testing.T) {
+	// A server that answers 304 to unconditional requests leaves no earlier copy to reuse
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer server.Close()
+
+	baseURL, _ := url.Parse(server.URL)
+	store := newMemoryStore(nil)
+	cfg := &config{
+		pages:              store,
+		baseURL:            baseURL,
+		mu:                 &sync.Mutex{},
+		concurrencyControl: make(chan struct{}, 1),
+		wg:                 &sync.WaitGroup{},
+	}
+	cfg.enqueue(server.URL, 0)
+	if err := cfg.crawl(); err != nil {
+		t.Fatal(err)
+	}
+
+	home := store.pages[baseURL.Host]
+	if home.URL != server.URL || home.StatusCode != 304 || !strings.Contains(home.FetchError, "304") || home.NotModified {
+		t.Errorf("expected the 304 to be recorded as a failure, got %+v", home)
+	}
+}
+
+// failingStore is a memoryStore whose queue or page writes fail
+type failingStore struct {
+	*memoryStore
+	failEnqueue bool
+	failPut     bool
+}
+
+func (s *failingStore) enqueue(urls []queuedURL) ([]bool, error) {
+	if s.failEnqueue && s.queueLen() > 0 {
+		return nil, errors.New("disk full")
+	}
+	return s.memoryStore.enqueue(urls)
+}
+
+func (s *failingStore) put(normalizedURL string, pageData PageData) error {
+	if s.failPut {
+		return errors.New("disk full")
+	}
+	return s.memoryStore.put(normalizedURL, pageData)
+}
+
+func TestCrawlPageStoreFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><a href="/next">Next</a></body></html>`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		store       *failingStore
+		expectedErr bool
+	}{
+		{name: "queueing links", store: &failingStore{memoryStore: newMemoryStore(nil), failEnqueue: true}},
+		{name: "storing the page", store: &failingStore{memoryStore: newMemoryStore(nil), failPut: true}, expectedErr: true},
+	}
+
+	for i, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			baseURL, _ := url.Parse(server.URL)
+			cfg := &config{
+				pages:              tc.store,
+				baseURL:            baseURL,
+				mu:                 &sync.Mutex{},
+				concurrencyControl: make(chan struct{}, 1),
+				wg:                 &sync.WaitGroup{},
+			}
+			cfg.enqueue(server.URL, 0)
+			err := cfg.crawl()
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("Test %v - '%s' FAIL: expected error %v, got %v", i, tc.name, tc.expectedErr, err)
+			}
+			if tc.expectedErr {
+				return
+			}
+			home := tc.store.pages[baseURL.Host]
+			if home.URL != server.URL || !strings.Contains(home.FetchError, "disk full") {
+				t.Errorf("Test %v - %s FAIL: expected the page to be stored with the queue error, got %+v", i, tc.name, home)
+			}
+		})
+	}
+}
+
+func TestCrawlFollowsRedirectedPages(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/docs/start", http.StatusFound)
+	})
+	mux.HandleFunc("/docs/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><a href="next">Next</a></body></html>`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	baseURL, _ := url.Parse(server.URL)
+	store := newMemoryStore(nil)
+	cfg := &config{
+		pages:              store,
+		baseURL:            baseURL,
+		mu:                 &sync.Mutex{},
+		concurrencyControl: make(chan struct{}, 1),
+		wg:                 &sync.WaitGroup{},
+		maxDepth:           1,
+	}
+	cfg.enqueue(server.URL, 0)
+	if err := cfg.crawl(); err != nil {
+		t.Fatal(err)
+	}
+
+	home := store.pages[baseURL.Host]
+	if home.URL != server.URL || home.RedirectedTo != server.URL+"/docs/start" {
+		t.Errorf("expected the redirect to be recorded, got %+v", home)
+	}
+	// Relative links resolve against the page the redirect led to
+	if _, ok := store.pages[baseURL.Host+"/docs/next"]; !ok {
+		t.Errorf("expected /docs/next to be crawled, got %v", store.pages)
+	}
+}
+
+func TestCrawlRecordsShortestDepth(t *testing.T) {
+	// /target is two clicks away through /slow, and three through /a and /b
+	links := map[string]string{
+		"/":       `<a href="/a">A</a><a href="/slow">Slow</a>`,
+		"/a":      `<a href="/b">B</a>`,
+		"/b":      `<a href="/target">Target</a>`,
+		"/slow":   `<a href="/target">Target</a>`,
+		"/target": ``,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := links[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><h1>` + r.URL.Path + `</h1>` + body + `</body></html>`))
+	}))
+	defer server.Close()
+
+	pages, _ := runCrawlWithConcurrency(server.URL, 5)
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	expected := map[string]int{host: 0, host + "/a": 1, host + "/slow": 1, host + "/b": 2, host + "/target": 2}
+	for pageURL, depth := range expected {
+		if pageData, ok := pages[pageURL]; !ok || pageData.Depth != depth {
+			t.Errorf("expected %s at depth %d, got %+v", pageURL, depth, pageData)
+		}
+	}
+}
+
+func TestCrawlDepthsMatchBreadthFirstSearch(t *testing.T) {
+	// A random site where slow pages hold up the shorter paths through them
+	rng := rand.New(rand.NewSource(1))
+	const pageCount = 60
+	links := make([][]int, pageCount)
+	for i := range links {
+		for j := 0; j < 3; j++ {
+			links[i] = append(links[i], rng.Intn(pageCount))
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var page int
+		if _, err := fmt.Sscanf(r.URL.Path, "/p%d", &page); err != nil || page < 0 || page >= pageCount {
+			http.NotFound(w, r)
+			return
+		}
+		time.Sleep(time.Duration(page%4) * 10 * time.Millisecond)
+		var body strings.Builder
+		fmt.Fprintf(&body, "<html><body><h1>page %d</h1>", page)
+		for _, target := range links[page] {
+			fmt.Fprintf(&body, `<a href="/p%d">%d</a>`, target, target)
+		}
+		body.WriteString("</body></html>")
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(body.String()))
+	}))
+	defer server.Close()
+
+	// Reference depths from a breadth-first search of the link graph
+	want := map[int]int{0: 0}
+	for queue := []int{0}; len(queue) > 0; queue = queue[1:] {
+		for _, target := range links[queue[0]] {
+			if _, seen := want[target]; !seen {
+				want[target] = want[queue[0]] + 1
+				queue = append(queue, target)
+			}
+		}
+	}
+
+	pages, _ := runCrawlWithConcurrency(server.URL+"/p0", 8)
+	host := strings.TrimPrefix(server.URL, "http://")
+	if len(pages) != len(want) {
+		t.Errorf("expected %d pages, got %d", len(want), len(pages))
+	}
+	for page, depth := range want {
+		if pageData := pages[fmt.Sprintf("%s/p%d", host, page)]; pageData.Depth != depth {
+			t.Errorf("expected /p%d at depth %d, got %d", page, depth, pageData.Depth)
+		}
+	}
+}
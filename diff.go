@@ -0,0 +1,309 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+)
+
+// crawlDiff lists what changed between two crawls of the same site
+type crawlDiff struct {
+	Added          []string        `json:"added"`
+	Removed        []string        `json:"removed"`
+	StatusChanges  []statusChange  `json:"status_changes"`
+	ContentChanges []contentChange `json:"content_changes"`
+	NewBrokenLinks []brokenLink    `json:"new_broken_links"`
+	LinkChanges    []linkChange    `json:"link_changes"`
+}
+
+// statusChange is a page whose HTTP status differs between crawls
+type statusChange struct {
+	URL       string `json:"url"`
+	OldStatus int    `json:"old_status"`
+	NewStatus int    `json:"new_status"`
+}
+
+// contentChange is a page whose title, h1 or first paragraph differs between crawls
+type contentChange struct {
+	URL   string `json:"url"`
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// brokenLink is a link to a page that failed in the new crawl but not the old one
+type brokenLink struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error"`
+}
+
+// linkChange lists the outgoing links a page gained or lost
+type linkChange struct {
+	URL     string   `json:"url"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// pageStatus returns a page's HTTP status, treating pages from crawls that didn't record it as 200
+func pageStatus(pageData PageData) int {
+	if pageData.StatusCode == 0 && pageData.URL != "" && pageData.FetchError == "" {
+		return 200
+	}
+	return pageData.StatusCode
+}
+
+// isBroken reports whether a page returned an error status or no response at all
+func isBroken(pageData PageData) bool {
+	status := pageStatus(pageData)
+	return status >= 400 || (status == 0 && pageData.FetchError != "")
+}
+
+// diffCrawls compares an old crawl with a new one, normalizing links with the policy each
+// crawl used. Both are read fully into memory.
+func diffCrawls(oldPages, newPages pageStore, oldPolicy, newPolicy normalizePolicy) (crawlDiff, error) {
+	collect := func(pages pageStore) (map[string]PageData, error) {
+		all := make(map[string]PageData)
+		err := pages.each(func(pageURL string, pageData PageData) error {
+			all[pageURL] = pageData
+			return nil
+		})
+		return all, err
+	}
+	before, err := collect(oldPages)
+	if err != nil {
+		return crawlDiff{}, err
+	}
+	after, err := collect(newPages)
+	if err != nil {
+		return crawlDiff{}, err
+	}
+
+	// Empty lists rather than nulls in the JSON output
+	diff := crawlDiff{
+		Added:          []string{},
+		Removed:        []string{},
+		StatusChanges:  []statusChange{},
+		ContentChanges: []contentChange{},
+		NewBrokenLinks: []brokenLink{},
+		LinkChanges:    []linkChange{},
+	}
+	for _, pageURL := range sortedKeys(after) {
+		newPage := after[pageURL]
+		oldPage, existed := before[pageURL]
+		if !existed {
+			diff.Added = append(diff.Added, displayURL(pageURL))
+			continue
+		}
+
+		if oldStatus, newStatus := pageStatus(oldPage), pageStatus(newPage); oldStatus != newStatus {
+			diff.StatusChanges = append(diff.StatusChanges, statusChange{displayURL(pageURL), oldStatus, newStatus})
+		}
+
+		// A page that failed in either crawl has no content to compare
+		if oldPage.FetchError == "" && newPage.FetchError == "" {
+			fields := []struct{ name, oldValue, newValue string }{
+				{"title", oldPage.Title, newPage.Title},
+				{"h1", oldPage.H1, newPage.H1},
+				{"first_paragraph", oldPage.FirstParagraph, newPage.FirstParagraph},
+			}
+			for _, field := range fields {
+				if field.oldValue != field.newValue {
+					diff.ContentChanges = append(diff.ContentChanges, contentChange{displayURL(pageURL), field.name, field.oldValue, field.newValue})
+				}
+			}
+
+			added, removed := diffLists(oldPage.OutgoingLinks, newPage.OutgoingLinks)
+			if len(added) > 0 || len(removed) > 0 {
+				diff.LinkChanges = append(diff.LinkChanges, linkChange{displayURL(pageURL), added, removed})
+			}
+		}
+	}
+	for _, pageURL := range sortedKeys(before) {
+		if _, exists := after[pageURL]; !exists {
+			diff.Removed = append(diff.Removed, displayURL(pageURL))
+		}
+	}
+
+	// A link is newly broken if its target fails now, and either the link is new or its target worked before
+	for _, pageURL := range sortedKeys(after) {
+		oldLinks := make(map[string]bool)
+		for _, link := range before[pageURL].OutgoingLinks {
+			oldLinks[link] = true
+		}
+		for _, link := range after[pageURL].OutgoingLinks {
+			targetURL, err := newPolicy.normalize(link)
+			if err != nil {
+				continue
+			}
+			target, crawled := after[targetURL]
+			if !crawled || !isBroken(target) {
+				continue
+			}
+			if oldTargetURL, err := oldPolicy.normalize(link); err == nil && oldLinks[link] {
+				if oldTarget, wasCrawled := before[oldTargetURL]; wasCrawled && isBroken(oldTarget) {
+					continue
+				}
+			}
+			diff.NewBrokenLinks = append(diff.NewBrokenLinks, brokenLink{
+				Source: displayURL(pageURL),
+				Target: link,
+				Status: target.StatusCode,
+				Error:  target.FetchError,
+			})
+		}
+	}
+
+	return diff, nil
+}
+
+// samePolicy reports whether two policies key pages alike, apart from parameters their learners learned
+func samePolicy(a, b normalizePolicy) bool {
+	a.Learner, b.Learner = nil, nil
+	return reflect.DeepEqual(a, b)
+}
+
+// diffLists returns the values only in newList and the values only in oldList, each sorted
+func diffLists(oldList, newList []string) (added, removed []string) {
+	inOld := make(map[string]bool)
+	for _, value := range oldList {
+		inOld[value] = true
+	}
+	inNew := make(map[string]bool)
+	for _, value := range newList {
+		inNew[value] = true
+		if !inOld[value] {
+			added = append(added, value)
+		}
+	}
+	for _, value := range oldList {
+		if !inNew[value] {
+			removed = append(removed, value)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// sortedKeys returns a map's keys in order
+func sortedKeys(pages map[string]PageData) []string {
+	keys := make([]string, 0, len(pages))
+	for key := range pages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeDiffText writes a crawl diff for people to read
+func writeDiffText(w io.Writer, diff crawlDiff) error {
+	var err error
+	printf := func(format string, args ...any) {
+		if err == nil {
+			_, err = fmt.Fprintf(w, format, args...)
+		}
+	}
+
+	printf("Pages added (%d):\n", len(diff.Added))
+	for _, pageURL := range diff.Added {
+		printf("  + %s\n", pageURL)
+	}
+	printf("\nPages removed (%d):\n", len(diff.Removed))
+	for _, pageURL := range diff.Removed {
+		printf("  - %s\n", pageURL)
+	}
+	printf("\nStatus changes (%d):\n", len(diff.StatusChanges))
+	for _, change := range diff.StatusChanges {
+		printf("  %s: %s -> %s\n", change.URL, formatStatus(change.OldStatus), formatStatus(change.NewStatus))
+	}
+	printf("\nContent changes (%d):\n", len(diff.ContentChanges))
+	for _, change := range diff.ContentChanges {
+		printf("  %s %s: %q -> %q\n", change.URL, change.Field, change.Old, change.New)
+	}
+	printf("\nNew broken links (%d):\n", len(diff.NewBrokenLinks))
+	for _, link := range diff.NewBrokenLinks {
+		printf("  %s -> %s (%s)\n", link.Source, link.Target, link.Error)
+	}
+	printf("\nLink changes (%d pages):\n", len(diff.LinkChanges))
+	for _, change := range diff.LinkChanges {
+		printf("  %s\n", change.URL)
+		for _, link := range change.Added {
+			printf("    + %s\n", link)
+		}
+		for _, link := range change.Removed {
+			printf("    - %s\n", link)
+		}
+	}
+	return err
+}
+
+// formatStatus shows a status code, or "no response" for 0
+func formatStatus(status int) string {
+	if status == 0 {
+		return "no response"
+	}
+	return fmt.Sprint(status)
+}
+
+// runDiff implements "crawler diff [-json file] <old pages.json> <new pages.json>" and returns the exit code
+func runDiff(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	jsonOut := flags.String("json", "", "also write the diff as JSON to this file")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: crawler diff [-json file] <old pages.json> <new pages.json>")
+		flags.PrintDefaults()
+	}
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return parseExitCode(err)
+	}
+	if len(positional) != 2 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	oldPages, newPages := newMemoryStore(nil), newMemoryStore(nil)
+	oldPolicy, err := loadJSONReport(positional[0], oldPages)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading old crawl: %v\n", err)
+		return exitCrawlError
+	}
+	newPolicy, err := loadJSONReport(positional[1], newPages)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading new crawl: %v\n", err)
+		return exitCrawlError
+	}
+	if !samePolicy(oldPolicy, newPolicy) {
+		fmt.Fprintln(os.Stderr, "warning: the crawls normalized URLs differently, so some pages may show as both added and removed")
+	}
+
+	diff, err := diffCrawls(oldPages, newPages, oldPolicy, newPolicy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error comparing crawls: %v\n", err)
+		return exitCrawlError
+	}
+	if err := writeDiffText(os.Stdout, diff); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing diff: %v\n", err)
+		return exitCrawlError
+	}
+
+	if *jsonOut != "" {
+		data, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error encoding diff: %v\n", err)
+			return exitCrawlError
+		}
+		if err := os.WriteFile(*jsonOut, append(data, '\n'), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing JSON diff: %v\n", err)
+			return exitCrawlError
+		}
+		fmt.Printf("\nJSON diff written to: %s\n", *jsonOut)
+	}
+	return exitOK
+}
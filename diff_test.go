@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func diffTestCrawls() (oldPages, newPages map[string]PageData) {
+	oldPages = map[string]PageData{
+		"example.com": {
+			URL:           "https://example.com",
+			Title:         "Home",
+			H1:            "Welcome",
+			OutgoingLinks: []string{"https://example.com/about", "https://example.com/old", "https://example.com/gone"},
+			StatusCode:    200,
+		},
+		"example.com/about": {URL: "https://example.com/about", H1: "About", StatusCode: 200},
+		"example.com/old":   {URL: "https://example.com/old", H1: "Old", StatusCode: 200},
+		"example.com/gone":  {URL: "https://example.com/gone", StatusCode: 404, FetchError: "error status code: 404"},
+	}
+	newPages = map[string]PageData{
+		"example.com": {
+			URL:           "https://example.com",
+			Title:         "Home",
+			H1:            "Welcome back",
+			OutgoingLinks: []string{"https://example.com/about", "https://example.com/new", "https://example.com/gone"},
+			StatusCode:    200,
+		},
+		"example.com/about": {URL: "https://example.com/about", StatusCode: 500, FetchError: "error status code: 500"},
+		"example.com/new":   {URL: "https://example.com/new", H1: "New", StatusCode: 200},
+		"example.com/gone":  {URL: "https://example.com/gone", StatusCode: 404, FetchError: "error status code: 404"},
+	}
+	return oldPages, newPages
+}
+
+func TestDiffCrawls(t *testing.T) {
+	oldPages, newPages := diffTestCrawls()
+
+	diff, err := diffCrawls(newMemoryStore(oldPages), newMemoryStore(newPages), normalizePolicy{}, normalizePolicy{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := crawlDiff{
+		Added:   []string{"example.com/new"},
+		Removed: []string{"example.com/old"},
+		StatusChanges: []statusChange{
+			{URL: "example.com/about", OldStatus: 200, NewStatus: 500},
+		},
+		ContentChanges: []contentChange{
+			{URL: "example.com", Field: "h1", Old: "Welcome", New: "Welcome back"},
+		},
+		// /gone was already broken and already linked, so only /about is new
+		NewBrokenLinks: []brokenLink{
+			{Source: "example.com", Target: "https://example.com/about", Status: 500, Error: "error status code: 500"},
+		},
+		LinkChanges: []linkChange{
+			{URL: "example.com", Added: []string{"https://example.com/new"}, Removed: []string{"https://example.com/old"}},
+		},
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("expected %+v, got %+v", expected, diff)
+	}
+}
+
+func TestDiffCrawlsIdentical(t *testing.T) {
+	oldPages, _ := diffTestCrawls()
+
+	diff, err := diffCrawls(newMemoryStore(oldPages), newMemoryStore(oldPages), normalizePolicy{}, normalizePolicy{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, _ := json.Marshal(diff)
+	expected := `{"added":[],"removed":[],"status_changes":[],"content_changes":[],"new_broken_links":[],"link_changes":[]}`
+	if string(data) != expected {
+		t.Errorf("expected an empty diff, got %s", data)
+	}
+}
+
+func TestDiffCrawlsOldFormatStatus(t *testing.T) {
+	// Crawls written before status codes were recorded count successful pages as 200
+	oldPages := map[string]PageData{"example.com": {URL: "https://example.com"}}
+	newPages := map[string]PageData{"example.com": {URL: "https://example.com", StatusCode: 200}}
+
+	diff, err := diffCrawls(newMemoryStore(oldPages), newMemoryStore(newPages), normalizePolicy{}, normalizePolicy{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(diff.StatusChanges) != 0 {
+		t.Errorf("expected no status changes, got %+v", diff.StatusChanges)
+	}
+}
+
+func TestDiffCrawlsUsesCrawlPolicy(t *testing.T) {
+	// The crawls kept queries, so each page of the list is its own page
+	policy := normalizePolicy{Query: queryKeepAll}
+	oldPages := map[string]PageData{
+		"example.com":             {URL: "https://example.com", StatusCode: 200, OutgoingLinks: []string{"https://example.com/list?page=2"}},
+		"example.com/list":        {URL: "https://example.com/list", StatusCode: 200},
+		"example.com/list?page=2": {URL: "https://example.com/list?page=2", StatusCode: 200},
+	}
+	newPages := map[string]PageData{
+		"example.com":             {URL: "https://example.com", StatusCode: 200, OutgoingLinks: []string{"https://example.com/list?page=2"}},
+		"example.com/list":        {URL: "https://example.com/list", StatusCode: 200},
+		"example.com/list?page=2": {URL: "https://example.com/list?page=2", StatusCode: 500, FetchError: "error status code: 500"},
+	}
+
+	diff, err := diffCrawls(newMemoryStore(oldPages), newMemoryStore(newPages), policy, policy)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []brokenLink{{Source: "example.com", Target: "https://example.com/list?page=2", Status: 500, Error: "error status code: 500"}}
+	if !reflect.DeepEqual(diff.NewBrokenLinks, expected) {
+		t.Errorf("expected %+v, got %+v", expected, diff.NewBrokenLinks)
+	}
+}
+
+func TestWriteDiffText(t *testing.T) {
+	oldPages, newPages := diffTestCrawls()
+	diff, _ := diffCrawls(newMemoryStore(oldPages), newMemoryStore(newPages), normalizePolicy{}, normalizePolicy{})
+
+	var out bytes.Buffer
+	if err := writeDiffText(&out, diff); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		"Pages added (1):\n  + example.com/new\n",
+		"Pages removed (1):\n  - example.com/old\n",
+		"  example.com/about: 200 -> 500\n",
+		`  example.com h1: "Welcome" -> "Welcome back"` + "\n",
+		"  example.com -> https://example.com/about (error status code: 500)\n",
+		"  example.com\n    + https://example.com/new\n    - https://example.com/old\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRunDiff(t *testing.T) {
+	oldPages, newPages := diffTestCrawls()
+	dir := t.TempDir()
+	oldFile := filepath.Join(dir, "old.json")
+	newFile := filepath.Join(dir, "new.json")
+	jsonFile := filepath.Join(dir, "diff.json")
+	writeJSONReport(newMemoryStore(oldPages), normalizePolicy{}, oldFile)
+	writeJSONReport(newMemoryStore(newPages), normalizePolicy{}, newFile)
+
+	if code := runDiff([]string{"-json", jsonFile, oldFile, newFile}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+
+	data, err := os.ReadFile(jsonFile)
+	if err != nil {
+		t.Fatalf("failed to read JSON diff: %v", err)
+	}
+	var diff crawlDiff
+	if err := json.Unmarshal(data, &diff); err != nil {
+		t.Fatalf("invalid JSON diff: %v", err)
+	}
+	if !reflect.DeepEqual(diff.Added, []string{"example.com/new"}) {
+		t.Errorf("unexpected added pages in JSON: %v", diff.Added)
+	}
+
+	if code := runDiff([]string{oldFile}); code != 2 {
+		t.Errorf("expected exit code 2 for a missing argument, got %d", code)
+	}
+}
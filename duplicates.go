@@ -0,0 +1,53 @@
+package main
+
+import "sort"
+
+// duplicateGroup is a set of URLs that served the same main content
+type duplicateGroup struct {
+	ContentHash string
+	URLs        []string // sorted
+}
+
+// registerContent records the first URL seen for a content hash and returns it.
+// A result other than normalizedURL means the page is a duplicate of that URL.
+func (cfg *config) registerContent(normalizedURL, contentHash string) string {
+	if contentHash == "" {
+		return normalizedURL
+	}
+	original, err := cfg.pages.claimContent(contentHash, normalizedURL)
+	if err != nil {
+		return normalizedURL
+	}
+	return original
+}
+
+// duplicateGroups groups crawled pages by content hash, keeping only hashes shared by more than one URL.
+// Groups are sorted by size, largest first, then by their first URL.
+func duplicateGroups(pages pageStore) ([]duplicateGroup, error) {
+	byHash := make(map[string][]string)
+	err := pages.each(func(pageURL string, pageData PageData) error {
+		if pageData.ContentHash != "" {
+			byHash[pageData.ContentHash] = append(byHash[pageData.ContentHash], pageURL)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []duplicateGroup
+	for hash, urls := range byHash {
+		if len(urls) < 2 {
+			continue
+		}
+		sort.Strings(urls)
+		groups = append(groups, duplicateGroup{ContentHash: hash, URLs: urls})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if len(groups[i].URLs) != len(groups[j].URLs) {
+			return len(groups[i].URLs) > len(groups[j].URLs)
+		}
+		return groups[i].URLs[0] < groups[j].URLs[0]
+	})
+	return groups, nil
+}
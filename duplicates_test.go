@@ -0,0 +1,159 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestDuplicateGroups(t *testing.T) {
+	pages := map[string]PageData{
+		"example.com":              {ContentHash: "aaa"},
+		"example.com/index.html":   {ContentHash: "aaa"},
+		"example.com/print/home":   {ContentHash: "aaa"},
+		"example.com/about":        {ContentHash: "bbb"},
+		"example.com/about?ref=1":  {ContentHash: "bbb"},
+		"example.com/contact":      {ContentHash: "ccc"},
+		"example.com/failed-fetch": {},
+		"example.com/also-failed":  {},
+	}
+
+	expected := []duplicateGroup{
+		{ContentHash: "aaa", URLs: []string{"example.com", "example.com/index.html", "example.com/print/home"}},
+		{ContentHash: "bbb", URLs: []string{"example.com/about", "example.com/about?ref=1"}},
+	}
+
+	actual, err := duplicateGroups(newMemoryStore(pages))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+}
+
+func TestRegisterContent(t *testing.T) {
+	cfg := &config{pages: newMemoryStore(nil), mu: &sync.Mutex{}}
+
+	if original := cfg.registerContent("example.com/a", "hash"); original != "example.com/a" {
+		t.Errorf("expected first URL to be the original, got %q", original)
+	}
+	if original := cfg.registerContent("example.com/b", "hash"); original != "example.com/a" {
+		t.Errorf("expected second URL to be a duplicate of example.com/a, got %q", original)
+	}
+	if original := cfg.registerContent("example.com/c", "other"); original != "example.com/c" {
+		t.Errorf("expected different content to be an original, got %q", original)
+	}
+
+	// Pages without content are never duplicates of each other
+	for _, pageURL := range []string{"example.com/img1", "example.com/img2"} {
+		if original := cfg.registerContent(pageURL, ""); original != pageURL {
+			t.Errorf("expected %s without content to be an original, got %q", pageURL, original)
+		}
+	}
+}
+
+func TestCrawlFollowsLinksFromEmptyPages(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><a href="/gallery/1"><img src="/1.png"></a><a href="/gallery/2"><img src="/2.png"></a></body></html>`))
+	})
+	// Image-only pages with no text, each linking to its own page
+	mux.HandleFunc("/gallery/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><a href="/photo` + r.URL.Path + `"><img src="/big.png"></a></body></html>`))
+	})
+	mux.HandleFunc("/photo/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><p>` + r.URL.Path + `</p></body></html>`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	baseURL, _ := url.Parse(server.URL)
+	store := newMemoryStore(nil)
+	cfg := &config{
+		pages:              store,
+		baseURL:            baseURL,
+		mu:                 &sync.Mutex{},
+		concurrencyControl: make(chan struct{}, 5),
+		wg:                 &sync.WaitGroup{},
+		skipDuplicateLinks: true,
+	}
+
+	cfg.enqueue(server.URL, 0)
+	if err := cfg.crawl(); err != nil {
+		t.Fatal(err)
+	}
+
+	for pageURL, pageData := range store.pages {
+		if pageData.DuplicateOf != "" {
+			t.Errorf("expected no duplicates among pages without text, but %s is a duplicate of %s", pageURL, pageData.DuplicateOf)
+		}
+	}
+	if len(store.pages) != 5 {
+		t.Errorf("expected links from both empty pages to be followed (5 pages), got %d", len(store.pages))
+	}
+}
+
+func TestCrawlSkipsLinksFromDuplicates(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><p>Home</p><a href="/a">A</a><a href="/copy">Copy</a></body></html>`))
+	})
+	// Same text as /copy, but each links somewhere different
+	mux.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><p>Same article</p><a href="/from-a">More</a></body></html>`))
+	})
+	mux.HandleFunc("/copy", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><p>Same   ARTICLE</p><a href="/from-copy">More</a></body></html>`))
+	})
+	mux.HandleFunc("/from-", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><p>` + r.URL.Path + `</p></body></html>`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	baseURL, _ := url.Parse(server.URL)
+	store := newMemoryStore(nil)
+	cfg := &config{
+		pages:              store,
+		baseURL:            baseURL,
+		mu:                 &sync.Mutex{},
+		concurrencyControl: make(chan struct{}, 5),
+		wg:                 &sync.WaitGroup{},
+		skipDuplicateLinks: true,
+	}
+
+	cfg.enqueue(server.URL, 0)
+	if err := cfg.crawl(); err != nil {
+		t.Fatal(err)
+	}
+
+	host := baseURL.Host
+	a, copyPage := store.pages[host+"/a"], store.pages[host+"/copy"]
+	if a.ContentHash == "" || a.ContentHash != copyPage.ContentHash {
+		t.Fatalf("expected /a and /copy to share a content hash, got %q and %q", a.ContentHash, copyPage.ContentHash)
+	}
+	if (a.DuplicateOf == "") == (copyPage.DuplicateOf == "") {
+		t.Errorf("expected exactly one of /a and /copy to be a duplicate, got %q and %q", a.DuplicateOf, copyPage.DuplicateOf)
+	}
+
+	// Only the original's link is followed
+	_, fromA := store.pages[host+"/from-a"]
+	_, fromCopy := store.pages[host+"/from-copy"]
+	if fromA == fromCopy {
+		t.Errorf("expected only the original's link to be crawled, got /from-a=%v /from-copy=%v", fromA, fromCopy)
+	}
+	if len(store.pages) != 4 {
+		t.Errorf("expected 4 pages, got %d", len(store.pages))
+	}
+}
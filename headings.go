@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Heading is an h1-h6 element with the lower-level headings nested beneath it
+type Heading struct {
+	Level    int       `json:"level"`
+	Text     string    `json:"text"`
+	Children []Heading `json:"children,omitempty"`
+}
+
+// HeadingOutline is the heading structure of a page
+type HeadingOutline struct {
+	Headings []Heading `json:"headings"`
+	Counts   [6]int    `json:"counts"` // Counts[0] is the number of h1s
+	Warnings []string  `json:"warnings,omitempty"`
+}
+
+// getHeadingOutlineFromHTML extracts every heading in document order, nests them and validates the structure
+func getHeadingOutlineFromHTML(htmlBody string) HeadingOutline {
+	doc, err := html.Parse(strings.NewReader(htmlBody))
+	if err != nil {
+		return HeadingOutline{}
+	}
+	return getHeadingOutlineFromDoc(doc)
+}
+
+// getHeadingOutlineFromDoc is getHeadingOutlineFromHTML for an already parsed page
+func getHeadingOutlineFromDoc(doc *html.Node) HeadingOutline {
+	var flat []Heading
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if level := headingLevel(n); level > 0 {
+			flat = append(flat, Heading{Level: level, Text: strings.Join(strings.Fields(extractText(n)), " ")})
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	var outline HeadingOutline
+	for _, h := range flat {
+		outline.Counts[h.Level-1]++
+	}
+	outline.Headings = nestHeadings(flat)
+	outline.Warnings = validateHeadings(flat, outline.Counts)
+	return outline
+}
+
+// headingLevel returns 1-6 for heading elements and 0 for anything else
+func headingLevel(n *html.Node) int {
+	if n.Type != html.ElementNode || len(n.Data) != 2 || n.Data[0] != 'h' {
+		return 0
+	}
+	if level := int(n.Data[1] - '0'); level >= 1 && level <= 6 {
+		return level
+	}
+	return 0
+}
+
+// nestHeadings turns a flat list of headings into a tree where each heading owns the deeper ones that follow it
+func nestHeadings(flat []Heading) []Heading {
+	var roots []Heading
+	for i := 0; i < len(flat); {
+		node := flat[i]
+		j := i + 1
+		for j < len(flat) && flat[j].Level > node.Level {
+			j++
+		}
+		node.Children = nestHeadings(flat[i+1 : j])
+		roots = append(roots, node)
+		i = j
+	}
+	return roots
+}
+
+// validateHeadings returns warnings for a missing or repeated h1 and for skipped heading levels
+func validateHeadings(flat []Heading, counts [6]int) []string {
+	var warnings []string
+
+	switch {
+	case counts[0] == 0:
+		warnings = append(warnings, "no h1")
+	case counts[0] > 1:
+		warnings = append(warnings, fmt.Sprintf("multiple h1 (%d)", counts[0]))
+	}
+
+	for _, skip := range skippedLevels(flat) {
+		warnings = append(warnings, fmt.Sprintf("skipped level: h%d after h%d (%q)", skip.Level, skip.Previous, skip.Text))
+	}
+
+	return warnings
+}
+
+// levelSkip is a heading more than one level deeper than the heading before it
+type levelSkip struct {
+	Heading
+	Previous int // level of the heading before it
+}
+
+// skippedLevels returns the headings that skip a level, such as an h4 right after an h2
+func skippedLevels(flat []Heading) []levelSkip {
+	var skips []levelSkip
+	previous := 0
+	for _, h := range flat {
+		if previous > 0 && h.Level > previous+1 {
+			skips = append(skips, levelSkip{Heading: h, Previous: previous})
+		}
+		previous = h.Level
+	}
+	return skips
+}
+
+// flatten returns the outline's headings in document order
+func (o HeadingOutline) flatten() []Heading {
+	var flat []Heading
+	var walk func([]Heading)
+	walk = func(headings []Heading) {
+		for _, h := range headings {
+			flat = append(flat, Heading{Level: h.Level, Text: h.Text})
+			walk(h.Children)
+		}
+	}
+	walk(o.Headings)
+	return flat
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetHeadingOutlineFromHTMLNesting(t *testing.T) {
+	inputBody := `<html><body>
+		<h1>Guide</h1>
+		<h2>Install</h2>
+		<h3>Linux</h3>
+		<h3>macOS</h3>
+		<h2>Usage</h2>
+	</body></html>`
+
+	actual := getHeadingOutlineFromHTML(inputBody)
+
+	expected := HeadingOutline{
+		Headings: []Heading{
+			{Level: 1, Text: "Guide", Children: []Heading{
+				{Level: 2, Text: "Install", Children: []Heading{
+					{Level: 3, Text: "Linux"},
+					{Level: 3, Text: "macOS"},
+				}},
+				{Level: 2, Text: "Usage"},
+			}},
+		},
+		Counts: [6]int{1, 2, 2, 0, 0, 0},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+}
+
+func TestGetHeadingOutlineFromHTMLWarnings(t *testing.T) {
+	tests := []struct {
+		name      string
+		inputBody string
+		expected  []string
+	}{
+		{
+			name:      "no h1",
+			inputBody: "<html><body><h2>Section</h2></body></html>",
+			expected:  []string{"no h1"},
+		},
+		{
+			name:      "multiple h1",
+			inputBody: "<html><body><h1>First Title</h1><h1>Second Title</h1></body></html>",
+			expected:  []string{"multiple h1 (2)"},
+		},
+		{
+			name:      "skipped level",
+			inputBody: "<html><body><h1>Title</h1><h4>Too <em>deep</em></h4></body></html>",
+			expected:  []string{`skipped level: h4 after h1 ("Too deep")`},
+		},
+		{
+			name:      "h1 after sections",
+			inputBody: "<html><body><h1>Title</h1><h2>Section</h2><h1>Footer</h1></body></html>",
+			expected:  []string{"multiple h1 (2)"},
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := getHeadingOutlineFromHTML(tc.inputBody).Warnings
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("Test %v - %s FAIL: expected warnings: %v, actual: %v", i, tc.name, tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestHeadingOutlineFlatten(t *testing.T) {
+	outline := getHeadingOutlineFromHTML("<h1>A</h1><h2>B</h2><h3>C</h3><h2>D</h2>")
+
+	var levels []int
+	for _, h := range outline.flatten() {
+		levels = append(levels, h.Level)
+	}
+
+	expected := []int{1, 2, 3, 2}
+	if !reflect.DeepEqual(levels, expected) {
+		t.Errorf("expected %v, got %v", expected, levels)
+	}
+}
@@ -3,6 +3,7 @@
 import (
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"golang.org/x/net/html"
@@ -11,10 +12,64 @@ import (
 // PageData represents extracted data from a web page
 type PageData struct {
 	URL            string
+	Title          string
 	H1             string
 	FirstParagraph string
 	OutgoingLinks  []string
 	ImageURLs      []string
+	Emails         []string
+	Phones         []string
+	ScriptLinks    []string
+	OtherLinks     []string
+	Assets         []Asset
+	Images         []ImageInfo
+	Outline        HeadingOutline
+	WordCount      int
+	ReadingMinutes int
+	StructuredData StructuredData
+	Charset        string
+
+	DeclaredContentType string
+	SniffedContentType  string
+
+	ContentHash string // fingerprint of the main content text
+	DuplicateOf string // first crawled URL with the same ContentHash, "" if this page is the original
+	SimHash     uint64 // near-duplicate fingerprint of the main content, 0 if too short
+
+	ETag         string
+	LastModified string
+	NotModified  bool // the page was unchanged since the previous crawl, whose data was reused
+
+	StatusCode int    // HTTP status of the fetch, 0 if no response was received
+	FetchError string // why the page couldn't be crawled, "" on success
+
+	RedirectedTo string // URL the page redirected to, whose content was crawled; "" if not redirected
+
+	NoIndex   bool   // a robots meta tag or X-Robots-Tag header asks search engines not to index the page
+	Canonical string // absolute rel="canonical" URL, "" if none
+
+	Depth        int           // links followed from the start page
+	FetchedAt    time.Time     // when the request was sent
+	ResponseTime time.Duration // until the body was read, or the fetch failed
+	Bytes        int           // size of the body as sent, 0 if unchanged or not read
+}
+
+// getTitleFromHTML extracts the text of the <title> tag from HTML
+func getTitleFromHTML(htmlBody string) string {
+	doc, err := html.Parse(strings.NewReader(htmlBody))
+	if err != nil {
+		return ""
+	}
+	return getTitleFromDoc(doc)
+}
+
+// getTitleFromDoc is getTitleFromHTML for an already parsed page
+func getTitleFromDoc(doc *html.Node) string {
+	titleNode := findNode(doc, "title")
+	if titleNode == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(extractText(titleNode)), " ")
 }
 
 // getH1FromHTML extracts the text content of the first <h1> tag from HTML
@@ -23,7 +78,11 @@ func getH1FromHTML(htmlBody string) string {
 	if err != nil {
 		return ""
 	}
+	return getH1FromDoc(doc)
+}
 
+// getH1FromDoc is getH1FromHTML for an already parsed page
+func getH1FromDoc(doc *html.Node) string {
 	var h1Text string
 	var findH1 func(*html.Node)
 	findH1 = func(n *html.Node) {
@@ -42,13 +101,17 @@ func getH1FromHTML(htmlBody string) string {
 	return h1Text
 }
 
-// getFirstParagraphFromHTML extracts the first <p> tag text, prioritizing <main> content
+// getFirstParagraphFromHTML extracts the first <p> tag text, prioritizing <main> content and skipping boilerplate
 func getFirstParagraphFromHTML(htmlBody string) string {
 	doc, err := html.Parse(strings.NewReader(htmlBody))
 	if err != nil {
 		return ""
 	}
+	return getFirstParagraphFromDoc(doc)
+}
 
+// getFirstParagraphFromDoc is getFirstParagraphFromHTML for an already parsed page
+func getFirstParagraphFromDoc(doc *html.Node) string {
 	// First, try to find <p> inside <main>
 	mainNode := findNode(doc, "main")
 	if mainNode != nil {
@@ -58,9 +121,11 @@ func getFirstParagraphFromHTML(htmlBody string) string {
 		}
 	}
 
-	// Fallback: find first <p> anywhere
-	pNode := findNode(doc, "p")
-	if pNode != nil {
+	// Fallback: first <p> in the main content, skipping nav, footers and cookie banners
+	if pNode := findContentNode(findContentRoot(doc), "p"); pNode != nil {
+		return strings.TrimSpace(extractText(pNode))
+	}
+	if pNode := findContentNode(doc, "p"); pNode != nil {
 		return strings.TrimSpace(extractText(pNode))
 	}
 
@@ -92,38 +157,27 @@ func extractText(n *html.Node) string {
 	return result.String()
 }
 
-// getURLsFromHTML extracts all URLs from anchor tags in the HTML
+// getURLsFromHTML extracts all navigable http(s) URLs from anchor tags in the HTML
 func getURLsFromHTML(htmlBody string, baseURL *url.URL) ([]string, error) {
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlBody))
+	links, err := getLinksFromHTML(htmlBody, baseURL)
 	if err != nil {
 		return nil, err
 	}
-
-	var urls []string
-	doc.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
-		href, exists := s.Attr("href")
-		if !exists || href == "" {
-			return
-		}
-
-		parsedURL, err := url.Parse(href)
-		if err != nil {
-			return
-		}
-
-		resolvedURL := baseURL.ResolveReference(parsedURL)
-		urls = append(urls, resolvedURL.String())
-	})
-
-	return urls, nil
+	return links.Navigable, nil
 }
 
 // getImagesFromHTML extracts all image URLs from img tags in the HTML
 func getImagesFromHTML(htmlBody string, baseURL *url.URL) ([]string, error) {
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlBody))
+	doc, err := html.Parse(strings.NewReader(htmlBody))
 	if err != nil {
 		return nil, err
 	}
+	return getImagesFromDoc(doc, baseURL), nil
+}
+
+// getImagesFromDoc is getImagesFromHTML for an already parsed page
+func getImagesFromDoc(root *html.Node, baseURL *url.URL) []string {
+	doc := goquery.NewDocumentFromNode(root)
 
 	var images []string
 	doc.Find("img[src]").Each(func(_ int, s *goquery.Selection) {
@@ -141,7 +195,7 @@ func getImagesFromHTML(htmlBody string, baseURL *url.URL) ([]string, error) {
 		images = append(images, resolvedURL.String())
 	})
 
-	return images, nil
+	return images
 }
 
 // extractPageData extracts all relevant data from a web page
@@ -151,14 +205,35 @@ func extractPageData(htmlBody string, rawURL string) PageData {
 		return PageData{URL: rawURL}
 	}
 
-	outgoingLinks, _ := getURLsFromHTML(htmlBody, baseURL)
-	imageURLs, _ := getImagesFromHTML(htmlBody, baseURL)
+	doc, err := html.Parse(strings.NewReader(htmlBody))
+	if err != nil {
+		return PageData{URL: rawURL}
+	}
+
+	links := getLinksFromDoc(doc, baseURL)
+	content := extractMainContentFromDoc(doc)
+	noIndex, canonical := getIndexingFromDoc(doc, baseURL)
 
 	return PageData{
 		URL:            rawURL,
-		H1:             getH1FromHTML(htmlBody),
-		FirstParagraph: getFirstParagraphFromHTML(htmlBody),
-		OutgoingLinks:  outgoingLinks,
-		ImageURLs:      imageURLs,
+		Title:          getTitleFromDoc(doc),
+		H1:             getH1FromDoc(doc),
+		FirstParagraph: getFirstParagraphFromDoc(doc),
+		OutgoingLinks:  links.Navigable,
+		ImageURLs:      getImagesFromDoc(doc, baseURL),
+		Emails:         links.Emails,
+		Phones:         links.Phones,
+		ScriptLinks:    links.Scripts,
+		OtherLinks:     links.Other,
+		Assets:         getAssetsFromDoc(doc, baseURL),
+		Images:         getImageDetailsFromDoc(doc, baseURL),
+		Outline:        getHeadingOutlineFromDoc(doc),
+		WordCount:      content.WordCount,
+		ReadingMinutes: content.ReadingMinutes,
+		StructuredData: getStructuredDataFromDoc(doc),
+		ContentHash:    contentFingerprint(content.Text),
+		SimHash:        simhash(content.Text),
+		NoIndex:        noIndex,
+		Canonical:      canonical,
 	}
 }
@@ -81,6 +81,20 @@ func TestGetFirstParagraphFromHTMLNoMain(t *testing.T) {
 	}
 }
 
+func TestGetFirstParagraphFromHTMLSkipsBoilerplate(t *testing.T) {
+	inputBody := `<html><body>
+		<div id="cookie-banner"><p>We use cookies.</p></div>
+		<nav><p>Menu</p></nav>
+		<article><p>Article paragraph.</p></article>
+	</body></html>`
+	actual := getFirstParagraphFromHTML(inputBody)
+	expected := "Article paragraph."
+
+	if actual != expected {
+		t.Errorf("expected %q, got %q", expected, actual)
+	}
+}
+
 func TestGetFirstParagraphFromHTMLNoParagraph(t *testing.T) {
 	inputBody := `<html><body><div>No paragraph here</div></body></html>`
 	actual := getFirstParagraphFromHTML(inputBody)
@@ -310,9 +324,47 @@ func TestExtractPageData(t *testing.T) {
 		FirstParagraph: "This is the first paragraph.",
 		OutgoingLinks:  []string{"https://blog.boot.dev/link1"},
 		ImageURLs:      []string{"https://blog.boot.dev/image1.jpg"},
+		Assets:         []Asset{{URL: "https://blog.boot.dev/image1.jpg", Kind: assetImage}},
+		Images:         []ImageInfo{{Src: "https://blog.boot.dev/image1.jpg", Alt: "Image 1", HasAlt: true}},
+		Outline: HeadingOutline{
+			Headings: []Heading{{Level: 1, Text: "Test Title"}},
+			Counts:   [6]int{1, 0, 0, 0, 0, 0},
+		},
+		WordCount:      9,
+		ReadingMinutes: 1,
+		ContentHash:    contentFingerprint(extractMainContent(inputBody).Text),
+		SimHash:        simhash(extractMainContent(inputBody).Text),
 	}
 
 	if !reflect.DeepEqual(actual, expected) {
 		t.Errorf("expected %+v, got %+v", expected, actual)
 	}
 }
+
+func TestGetTitleFromHTML(t *testing.T) {
+	tests := []struct {
+		name      string
+		inputBody string
+		expected  string
+	}{
+		{
+			name:      "title in head",
+			inputBody: "<html><head><title>  Boot.dev Blog </title></head><body><h1>Posts</h1></body></html>",
+			expected:  "Boot.dev Blog",
+		},
+		{
+			name:      "no title",
+			inputBody: "<html><body><h1>Posts</h1></body></html>",
+			expected:  "",
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := getTitleFromHTML(tc.inputBody)
+			if actual != tc.expected {
+				t.Errorf("Test %v - %s FAIL: expected title: %q, actual: %q", i, tc.name, tc.expected, actual)
+			}
+		})
+	}
+}
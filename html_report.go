@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bufio"
+	"html/template"
+	"os"
+	"sort"
+	"time"
+)
+
+// htmlReportSummary is the dashboard at the top of the HTML report
+type htmlReportSummary struct {
+	BaseURL     string
+	GeneratedAt string
+	Pages       int
+	Statuses    []statusCount
+	BrokenLinks []brokenLink
+	MissingH1   []string
+}
+
+// statusCount is one bar of the status code histogram
+type statusCount struct {
+	Status  string
+	Count   int
+	Percent int // of the largest count, for the bar width
+}
+
+// htmlReportPage is one page's row and detail view
+type htmlReportPage struct {
+	ID      int
+	URL     string
+	Status  string
+	Issues  []accessibilityIssue
+	Outline string
+	Page    PageData
+}
+
+// writeHTMLReport writes a single self-contained HTML file with a summary dashboard, a sortable
+// and filterable page table, and a detail view per page. Pages are streamed from the store
+// in several passes, so the whole crawl is never held in memory.
+func writeHTMLReport(pages pageStore, baseURL string, policy normalizePolicy, filename string) error {
+	summary, err := summarizeForHTML(pages, baseURL, policy)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := bufio.NewWriter(file)
+
+	// Rows and detail views are written in separate passes over the store
+	writePages := func(section string) error {
+		id := 0
+		return pages.each(func(pageURL string, pageData PageData) error {
+			id++
+			page := htmlReportPage{
+				ID:      id,
+				URL:     displayURL(pageURL),
+				Status:  formatStatus(pageStatus(pageData)),
+				Issues:  findAccessibilityIssues(pageData),
+				Outline: formatOutline(pageData.Outline),
+				Page:    pageData,
+			}
+			return htmlReportTemplate.ExecuteTemplate(writer, section, page)
+		})
+	}
+
+	if err := htmlReportTemplate.ExecuteTemplate(writer, "head", summary); err != nil {
+		return err
+	}
+	if err := writePages("row"); err != nil {
+		return err
+	}
+	if err := htmlReportTemplate.ExecuteTemplate(writer, "table-end", nil); err != nil {
+		return err
+	}
+	if err := writePages("detail"); err != nil {
+		return err
+	}
+	if err := htmlReportTemplate.ExecuteTemplate(writer, "foot", nil); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// summarizeForHTML counts statuses and finds broken links and pages without an h1
+func summarizeForHTML(pages pageStore, baseURL string, policy normalizePolicy) (htmlReportSummary, error) {
+	summary := htmlReportSummary{
+		BaseURL:     baseURL,
+		GeneratedAt: time.Now().Format("2006-01-02 15:04 MST"),
+	}
+	counts := make(map[string]int)
+
+	err := pages.each(func(pageURL string, pageData PageData) error {
+		summary.Pages++
+		counts[formatStatus(pageStatus(pageData))]++
+		if pageData.FetchError == "" && pageData.H1 == "" {
+			summary.MissingH1 = append(summary.MissingH1, displayURL(pageURL))
+		}
+		return nil
+	})
+	if err != nil {
+		return htmlReportSummary{}, err
+	}
+	summary.BrokenLinks, err = findBrokenLinks(pages, policy)
+	if err != nil {
+		return htmlReportSummary{}, err
+	}
+
+	largest := 0
+	for status, count := range counts {
+		summary.Statuses = append(summary.Statuses, statusCount{Status: status, Count: count})
+		largest = max(largest, count)
+	}
+	sort.Slice(summary.Statuses, func(i, j int) bool { return summary.Statuses[i].Status < summary.Statuses[j].Status })
+	for i := range summary.Statuses {
+		summary.Statuses[i].Percent = summary.Statuses[i].Count * 100 / largest
+	}
+	return summary, nil
+}
+
+// htmlReportTemplate holds the report's sections. Styles and scripts are inline so the file works offline.
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{"formatStatus": formatStatus}).Parse(`
+{{define "head"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Crawl report: {{.BaseURL}}</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem; color: #222; }
+h1 { font-size: 1.5rem; }
+.cards { display: flex; gap: 1rem; flex-wrap: wrap; }
+.card { border: 1px solid #ddd; border-radius: 6px; padding: 1rem; min-width: 12rem; }
+.card .number { font-size: 2rem; font-weight: bold; }
+.bar { background: #4a7bd0; height: 0.8rem; display: inline-block; vertical-align: middle; }
+table { border-collapse: collapse; width: 100%; margin-top: 1rem; }
+th, td { border-bottom: 1px solid #eee; padding: 0.3rem 0.5rem; text-align: left; vertical-align: top; }
+th { cursor: pointer; background: #f6f6f6; user-select: none; }
+th.asc::after { content: " \25B2"; }
+th.desc::after { content: " \25BC"; }
+td.num { text-align: right; }
+.error { color: #b00020; }
+#filter { padding: 0.4rem; width: 24rem; max-width: 100%; }
+.detail { display: none; border: 1px solid #ddd; border-radius: 6px; padding: 1rem; margin-top: 1rem; }
+.detail:target { display: block; }
+pre { background: #f6f6f6; padding: 0.5rem; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>Crawl report: {{.BaseURL}}</h1>
+<p>Generated {{.GeneratedAt}}</p>
+
+<div class="cards">
+<div class="card"><div class="number">{{.Pages}}</div>pages crawled</div>
+<div class="card"><div class="number">{{len .BrokenLinks}}</div>broken links</div>
+<div class="card"><div class="number">{{len .MissingH1}}</div>pages missing an h1</div>
+<div class="card">
+<strong>Status codes</strong>
+<table>{{range .Statuses}}
+<tr><td>{{.Status}}</td><td class="num">{{.Count}}</td><td><span class="bar" style="width: {{.Percent}}px"></span></td></tr>{{end}}
+</table>
+</div>
+</div>
+
+{{if .BrokenLinks}}<h2>Broken links</h2>
+<table>
+<tr><th>Page</th><th>Link</th><th>Problem</th></tr>{{range .BrokenLinks}}
+<tr><td>{{.Source}}</td><td>{{.Target}}</td><td class="error">{{if .Error}}{{.Error}}{{else}}{{formatStatus .Status}}{{end}}</td></tr>{{end}}
+</table>{{end}}
+
+{{if .MissingH1}}<h2>Pages missing an h1</h2>
+<ul>{{range .MissingH1}}
+<li>{{.}}</li>{{end}}
+</ul>{{end}}
+
+<h2>Pages</h2>
+<input id="filter" type="search" placeholder="Filter pages">
+<table id="pages">
+<thead><tr><th>URL</th><th>Status</th><th>Title</th><th>H1</th><th>Words</th><th>Links</th><th>Images</th><th>Issues</th></tr></thead>
+<tbody>
+{{end}}
+
+{{define "row"}}<tr><td><a href="#page-{{.ID}}">{{.URL}}</a></td><td>{{.Status}}</td><td>{{.Page.Title}}</td><td>{{.Page.H1}}</td><td class="num">{{.Page.WordCount}}</td><td class="num">{{len .Page.OutgoingLinks}}</td><td class="num">{{len .Page.Images}}</td><td class="num">{{len .Issues}}</td></tr>
+{{end}}
+
+{{define "table-end"}}</tbody>
+</table>
+
+<h2>Page details</h2>
+<p>Select a page in the table to see its details.</p>
+{{end}}
+
+{{define "detail"}}<section class="detail" id="page-{{.ID}}">
+<h3>{{.URL}}</h3>
+<p><a href="{{.Page.URL}}">{{.Page.URL}}</a> &middot; status {{.Status}}{{if .Page.FetchError}} &middot; <span class="error">{{.Page.FetchError}}</span>{{end}}</p>
+<dl>
+<dt>Title</dt><dd>{{.Page.Title}}</dd>
+<dt>H1</dt><dd>{{.Page.H1}}</dd>
+<dt>First paragraph</dt><dd>{{.Page.FirstParagraph}}</dd>
+<dt>Content type</dt><dd>{{.Page.DeclaredContentType}} {{.Page.Charset}}</dd>
+<dt>Words</dt><dd>{{.Page.WordCount}} ({{.Page.ReadingMinutes}} min read)</dd>{{if .Page.DuplicateOf}}
+<dt>Duplicate of</dt><dd>{{.Page.DuplicateOf}}</dd>{{end}}
+</dl>{{if .Outline}}
+<h4>Heading outline</h4>
+<pre>{{.Outline}}</pre>{{end}}{{if .Issues}}
+<h4>Issues</h4>
+<ul>{{range .Issues}}
+<li>{{.Issue}}: {{.Element}} {{.Detail}}</li>{{end}}
+</ul>{{end}}{{if .Page.OutgoingLinks}}
+<h4>Outgoing links</h4>
+<ul>{{range .Page.OutgoingLinks}}
+<li>{{.}}</li>{{end}}
+</ul>{{end}}{{if .Page.Images}}
+<h4>Images</h4>
+<ul>{{range .Page.Images}}
+<li>{{.Src}}{{if .HasAlt}} &mdash; alt "{{.Alt}}"{{else}} <span class="error">(no alt)</span>{{end}}</li>{{end}}
+</ul>{{end}}{{if .Page.Assets}}
+<h4>Assets</h4>
+<ul>{{range .Page.Assets}}
+<li>{{.Kind}} {{.URL}}{{if .StatusCode}} ({{.StatusCode}}){{end}}{{if .Error}} <span class="error">{{.Error}}</span>{{end}}</li>{{end}}
+</ul>{{end}}
+</section>
+{{end}}
+
+{{define "foot"}}<script>
+(function () {
+  var table = document.getElementById("pages");
+  var body = table.tBodies[0];
+
+  document.getElementById("filter").addEventListener("input", function (e) {
+    var query = e.target.value.toLowerCase();
+    Array.prototype.forEach.call(body.rows, function (row) {
+      row.style.display = row.textContent.toLowerCase().indexOf(query) === -1 ? "none" : "";
+    });
+  });
+
+  Array.prototype.forEach.call(table.tHead.rows[0].cells, function (th, column) {
+    th.addEventListener("click", function () {
+      var ascending = !th.classList.contains("asc");
+      Array.prototype.forEach.call(table.tHead.rows[0].cells, function (other) {
+        other.classList.remove("asc", "desc");
+      });
+      th.classList.add(ascending ? "asc" : "desc");
+
+      var rows = Array.prototype.slice.call(body.rows);
+      rows.sort(function (a, b) {
+        var x = a.cells[column].textContent, y = b.cells[column].textContent;
+        var nx = parseFloat(x), ny = parseFloat(y);
+        var order = !isNaN(nx) && !isNaN(ny) ? nx - ny : x.localeCompare(y);
+        return ascending ? order : -order;
+      });
+      rows.forEach(function (row) { body.appendChild(row); });
+    });
+  });
+})();
+</script>
+</body>
+</html>
+{{end}}`))
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func htmlReportTestPages() *memoryStore {
+	return newMemoryStore(map[string]PageData{
+		"example.com": {
+			URL:           "https://example.com",
+			Title:         "Home",
+			H1:            "Welcome",
+			OutgoingLinks: []string{"https://example.com/about", "https://example.com/missing"},
+			Images:        []ImageInfo{{Src: "https://example.com/logo.png"}},
+			StatusCode:    200,
+		},
+		"example.com/about":   {URL: "https://example.com/about", Title: "About <us>", StatusCode: 200},
+		"example.com/missing": {URL: "https://example.com/missing", StatusCode: 404, FetchError: "error status code: 404"},
+	})
+}
+
+func TestSummarizeForHTML(t *testing.T) {
+	summary, err := summarizeForHTML(htmlReportTestPages(), "https://example.com", normalizePolicy{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if summary.Pages != 3 {
+		t.Errorf("expected 3 pages, got %d", summary.Pages)
+	}
+	expectedStatuses := []statusCount{{Status: "200", Count: 2, Percent: 100}, {Status: "404", Count: 1, Percent: 50}}
+	if !reflect.DeepEqual(summary.Statuses, expectedStatuses) {
+		t.Errorf("expected statuses %+v, got %+v", expectedStatuses, summary.Statuses)
+	}
+	expectedBroken := []brokenLink{{Source: "example.com", Target: "https://example.com/missing", Status: 404, Error: "error status code: 404"}}
+	if !reflect.DeepEqual(summary.BrokenLinks, expectedBroken) {
+		t.Errorf("expected broken links %+v, got %+v", expectedBroken, summary.BrokenLinks)
+	}
+	// The failed page isn't counted as missing an h1
+	if !reflect.DeepEqual(summary.MissingH1, []string{"example.com/about"}) {
+		t.Errorf("expected only /about to be missing an h1, got %v", summary.MissingH1)
+	}
+}
+
+func TestWriteHTMLReport(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "report.html")
+	if err := writeHTMLReport(htmlReportTestPages(), "https://example.com", normalizePolicy{}, filename); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("failed to read report: %v", err)
+	}
+	report := string(data)
+
+	for _, want := range []string{
+		"<title>Crawl report: https://example.com</title>",
+		`<div class="number">3</div>pages crawled`,
+		`<a href="#page-1">example.com</a>`,
+		`<section class="detail" id="page-3">`,
+		"About &lt;us&gt;",
+		"(no alt)",
+		"<script>",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("expected report to contain %q", want)
+		}
+	}
+
+	// Self-contained: nothing is loaded from elsewhere
+	for _, external := range []string{"<link ", "src=\"http", "@import"} {
+		if strings.Contains(report, external) {
+			t.Errorf("expected no external resources, found %q", external)
+		}
+	}
+}
+
+func TestWriteHTMLReportBrokenLinkWithoutError(t *testing.T) {
+	// A page can fail with a status but no fetch error, as crawls from before fetch errors were recorded do
+	pages := newMemoryStore(map[string]PageData{
+		"example.com":      {URL: "https://example.com", H1: "Home", StatusCode: 200, OutgoingLinks: []string{"https://example.com/gone"}},
+		"example.com/gone": {URL: "https://example.com/gone", StatusCode: 410},
+	})
+	filename := filepath.Join(t.TempDir(), "report.html")
+	if err := writeHTMLReport(pages, "https://example.com", normalizePolicy{}, filename); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("failed to read report: %v", err)
+	}
+	if want := `<td>https://example.com/gone</td><td class="error">410</td>`; !strings.Contains(string(data), want) {
+		t.Errorf("expected the broken link's status as its problem, want %q", want)
+	}
+}
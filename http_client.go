@@ -1,41 +1,255 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
 )
 
+// userAgent identifies the crawler in every request it sends, unless another is configured
+const userAgent = "BootCrawler/1.0"
+
+// defaultAcceptedTypes are the media types crawled as HTML when no others are configured
+var defaultAcceptedTypes = []string{"text/html", "application/xhtml+xml"}
+
+// defaultMaxBodyBytes is the largest page body read when no other limit is configured
+const defaultMaxBodyBytes = 10 << 20
+
+// sniffLen is how much of a body http.DetectContentType looks at
+const sniffLen = 512
+
+// genericTypes are Content-Types servers send when they don't know better, so the body is sniffed instead
+var genericTypes = map[string]bool{
+	"":                         true,
+	"text/plain":               true,
+	"application/octet-stream": true,
+	"binary/octet-stream":      true,
+}
+
+// fetchOptions controls which responses fetchPage accepts
+type fetchOptions struct {
+	AcceptedTypes []string      // media types treated as HTML; defaultAcceptedTypes when empty
+	UserAgent     string        // userAgent when empty
+	Timeout       time.Duration // for the whole request including the body; 0 waits forever
+	MaxBodyBytes  int64         // larger bodies are rejected; defaultMaxBodyBytes when 0
+
+	// Validators from a previous fetch of the same URL, sent as If-None-Match and
+	// If-Modified-Since so an unchanged page can be answered with 304 Not Modified
+	ETag         string
+	LastModified string
+}
+
+// accepts reports whether a media type is one the crawler should parse
+func (opts fetchOptions) accepts(mediaType string) bool {
+	accepted := opts.AcceptedTypes
+	if len(accepted) == 0 {
+		accepted = defaultAcceptedTypes
+	}
+	for _, t := range accepted {
+		if strings.EqualFold(t, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchResult is a fetched page body, decoded to UTF-8, along with details about how it was served
+type fetchResult struct {
+	Body    string
+	Charset string // declared encoding the body was decoded from, e.g. "shift_jis", "" if guessed
+	Bytes   int    // size of the body as sent, before decoding
+
+	DeclaredType string // media type from the Content-Type header, "" if missing
+	SniffedType  string // media type detected from the body's leading bytes
+
+	StatusCode   int
+	FinalURL     string // where redirects led, "" if the requested URL answered itself
+	ETag         string
+	LastModified string
+	NotModified  bool   // the server answered 304, so there is no body
+	RobotsTag    string // X-Robots-Tag header, e.g. "noindex, nofollow"
+}
+
 // getHTML fetches the HTML content from the given URL
 func getHTML(rawURL string) (string, error) {
-	req, err := http.NewRequest("GET", rawURL, nil)
+	result, err := fetchPage(rawURL, fetchOptions{})
 	if err != nil {
 		return "", err
 	}
+	return result.Body, nil
+}
+
+// fetchPage fetches an HTML page and transcodes its body to UTF-8
+func fetchPage(rawURL string, opts fetchOptions) (fetchResult, error) {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return fetchResult{}, err
+	}
 
-	req.Header.Set("User-Agent", "BootCrawler/1.0")
+	agent := opts.UserAgent
+	if agent == "" {
+		agent = userAgent
+	}
+	req.Header.Set("User-Agent", agent)
+	if opts.ETag != "" {
+		req.Header.Set("If-None-Match", opts.ETag)
+	}
+	if opts.LastModified != "" {
+		req.Header.Set("If-Modified-Since", opts.LastModified)
+	}
 
-	client := &http.Client{}
+	client := &http.Client{Timeout: opts.Timeout}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return fetchResult{}, err
 	}
 	defer resp.Body.Close()
 
+	// The client follows redirects with new requests, so the response's is the last one
+	finalURL := ""
+	if resp.Request != req {
+		finalURL = resp.Request.URL.String()
+	}
+
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if resp.StatusCode == http.StatusNotModified {
+		// A 304 may omit validators that haven't changed
+		if etag == "" {
+			etag = opts.ETag
+		}
+		if lastModified == "" {
+			lastModified = opts.LastModified
+		}
+		return fetchResult{StatusCode: resp.StatusCode, ETag: etag, LastModified: lastModified, NotModified: true}, nil
+	}
+
 	if resp.StatusCode >= 400 {
-		return "", fmt.Errorf("error status code: %d", resp.StatusCode)
+		return fetchResult{StatusCode: resp.StatusCode}, fmt.Errorf("error status code: %d", resp.StatusCode)
 	}
 
 	contentType := resp.Header.Get("Content-Type")
-	if !strings.HasPrefix(contentType, "text/html") {
-		return "", fmt.Errorf("unexpected content type: %s", contentType)
+	declaredType := mediaType(contentType)
+	if !opts.accepts(declaredType) && !genericTypes[declaredType] {
+		return fetchResult{StatusCode: resp.StatusCode}, fmt.Errorf("unexpected content type: %s", contentType)
+	}
+
+	maxBytes := opts.MaxBodyBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxBodyBytes
+	}
+	reader := bufio.NewReader(io.LimitReader(resp.Body, maxBytes+1))
+
+	// A missing or generic Content-Type is only trusted if the body itself looks like HTML,
+	// which the leading bytes tell without downloading the rest of a mislabeled file
+	head, err := reader.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return fetchResult{StatusCode: resp.StatusCode}, err
+	}
+	sniffedType := mediaType(http.DetectContentType(head))
+	if !opts.accepts(declaredType) && !opts.accepts(sniffedType) {
+		return fetchResult{StatusCode: resp.StatusCode}, fmt.Errorf("unexpected content type: %s (sniffed %s)", contentType, sniffedType)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := io.ReadAll(reader)
 	if err != nil {
-		return "", err
+		return fetchResult{StatusCode: resp.StatusCode}, err
+	}
+	if int64(len(body)) > maxBytes {
+		return fetchResult{StatusCode: resp.StatusCode}, fmt.Errorf("body larger than %d bytes", maxBytes)
+	}
+
+	decoded, charsetName, err := decodeHTMLBody(body, contentType)
+	if err != nil {
+		return fetchResult{StatusCode: resp.StatusCode}, err
+	}
+
+	return fetchResult{
+		Body:         decoded,
+		Charset:      charsetName,
+		Bytes:        len(body),
+		DeclaredType: declaredType,
+		SniffedType:  sniffedType,
+		StatusCode:   resp.StatusCode,
+		FinalURL:     finalURL,
+		ETag:         etag,
+		LastModified: lastModified,
+		RobotsTag:    strings.Join(resp.Header.Values("X-Robots-Tag"), ", "),
+	}, nil
+}
+
+// mediaType returns the lowercased media type of a Content-Type value without its parameters
+func mediaType(contentType string) string {
+	if parsed, _, err := mime.ParseMediaType(contentType); err == nil {
+		return parsed
+	}
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+// decodeHTMLBody detects the body's character set from its BOM, the Content-Type charset
+// or a <meta charset> tag, and transcodes it to UTF-8. The returned charset name is "" when
+// nothing declares one and the encoding was guessed.
+func decodeHTMLBody(body []byte, contentType string) (string, string, error) {
+	enc, name, certain := charset.DetermineEncoding(body, contentType)
+	declared := name
+	if !certain && !declaresMetaCharset(body) {
+		declared = ""
+	}
+	if !certain && utf8.Valid(body) {
+		// Without a declaration, valid UTF-8 is far more likely than the windows-1252 default
+		if declared != "" {
+			declared = "utf-8"
+		}
+		return string(body), declared, nil
+	}
+	if name == "utf-8" {
+		return string(body), declared, nil
 	}
 
-	return string(body), nil
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return "", declared, fmt.Errorf("decoding %s body: %w", name, err)
+	}
+	return string(decoded), declared, nil
+}
+
+// declaresMetaCharset reports whether the start of an HTML body names its encoding with
+// <meta charset> or <meta http-equiv="Content-Type" content="...; charset=...">
+func declaresMetaCharset(body []byte) bool {
+	if len(body) > 1024 {
+		body = body[:1024]
+	}
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return false
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			if string(name) != "meta" {
+				continue
+			}
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				switch string(key) {
+				case "charset":
+					return true
+				case "content":
+					if strings.Contains(strings.ToLower(string(val)), "charset=") {
+						return true
+					}
+				}
+			}
+		}
+	}
 }
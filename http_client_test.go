@@ -5,6 +5,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetHTMLSuccess(t *testing.T) {
@@ -80,9 +81,378 @@ func TestGetHTMLUserAgent(t *testing.T) {
 	}
 }
 
+func TestFetchPageCustomUserAgent(t *testing.T) {
+	var receivedUserAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedUserAgent = r.Header.Get("User-Agent")
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	if _, err := fetchPage(server.URL, fetchOptions{UserAgent: "TestBot/2.0"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if receivedUserAgent != "TestBot/2.0" {
+		t.Errorf("expected User-Agent 'TestBot/2.0', got %s", receivedUserAgent)
+	}
+}
+
+func TestFetchPageTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	_, err := fetchPage(server.URL, fetchOptions{Timeout: 50 * time.Millisecond})
+	if err == nil {
+		t.Fatal("expected a timeout error")
+	}
+	if !strings.Contains(err.Error(), "Timeout") {
+		t.Errorf("expected a timeout error, got %v", err)
+	}
+}
+
 func TestGetHTMLInvalidURL(t *testing.T) {
 	_, err := getHTML("not-a-valid-url")
 	if err == nil {
 		t.Fatal("expected error for invalid URL, got nil")
 	}
 }
+
+func TestFetchPageDecodesCharsetFromHeader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=windows-1252")
+		w.WriteHeader(http.StatusOK)
+		// "Café" encoded as windows-1252
+		w.Write([]byte("<html><body><h1>Caf\xe9</h1></body></html>"))
+	}))
+	defer server.Close()
+
+	result, err := fetchPage(server.URL, fetchOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(result.Body, "<h1>Café</h1>") {
+		t.Errorf("expected body to be transcoded to UTF-8, got %q", result.Body)
+	}
+	if result.Charset != "windows-1252" {
+		t.Errorf("expected charset windows-1252, got %q", result.Charset)
+	}
+}
+
+func TestFetchPageDecodesCharsetFromMeta(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusOK)
+		// "日本" encoded as Shift_JIS
+		w.Write([]byte(`<html><head><meta charset="Shift_JIS"></head><body><h1>` + "\x93\xfa\x96\x7b" + `</h1></body></html>`))
+	}))
+	defer server.Close()
+
+	result, err := fetchPage(server.URL, fetchOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(result.Body, "<h1>日本</h1>") {
+		t.Errorf("expected body to be transcoded to UTF-8, got %q", result.Body)
+	}
+	if result.Charset != "shift_jis" {
+		t.Errorf("expected charset shift_jis, got %q", result.Charset)
+	}
+}
+
+func TestFetchPageDefaultsToUTF8(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("<html><body><h1>Crème brûlée</h1></body></html>"))
+	}))
+	defer server.Close()
+
+	result, err := fetchPage(server.URL, fetchOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.Contains(result.Body, "<h1>Crème brûlée</h1>") {
+		t.Errorf("expected UTF-8 body to be unchanged, got %q", result.Body)
+	}
+	if result.Charset != "" {
+		t.Errorf("expected no charset for an undeclared encoding, got %q", result.Charset)
+	}
+}
+
+func TestFetchPageRecordsRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body><h1>New</h1></body></html>"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{name: "redirected", path: "/old", expected: server.URL + "/new"},
+		{name: "served directly", path: "/new", expected: ""},
+	}
+
+	for i, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := fetchPage(server.URL+tc.path, fetchOptions{})
+			if err != nil {
+				t.Fatalf("Test %v - '%s' FAIL: unexpected error: %v", i, tc.name, err)
+			}
+			if result.FinalURL != tc.expected {
+				t.Errorf("Test %v - %s FAIL: expected final URL %q, got %q", i, tc.name, tc.expected, result.FinalURL)
+			}
+		})
+	}
+}
+
+func TestDecodeHTMLBodyCharset(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		contentType string
+		expected    string
+	}{
+		{name: "header", body: "<p>Caf\xe9</p>", contentType: "text/html; charset=windows-1252", expected: "windows-1252"},
+		{name: "meta charset", body: `<meta charset="utf-8"><p>Café</p>`, contentType: "text/html", expected: "utf-8"},
+		{name: "meta http-equiv", body: "<meta http-equiv=\"Content-Type\" content=\"text/html; charset=iso-8859-1\"><p>Caf\xe9</p>", contentType: "text/html", expected: "windows-1252"},
+		{name: "undeclared utf-8", body: "<p>Café</p>", contentType: "text/html", expected: ""},
+		{name: "undeclared legacy bytes", body: "<p>Caf\xe9</p>", contentType: "text/html", expected: ""},
+	}
+
+	for i, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			decoded, actual, err := decodeHTMLBody([]byte(tc.body), tc.contentType)
+			if err != nil {
+				t.Fatalf("Test %v - '%s' FAIL: unexpected error: %v", i, tc.name, err)
+			}
+			if actual != tc.expected {
+				t.Errorf("Test %v - %s FAIL: expected charset %q, got %q", i, tc.name, tc.expected, actual)
+			}
+			if !strings.Contains(decoded, "Café") {
+				t.Errorf("Test %v - %s FAIL: expected decoded text, got %q", i, tc.name, decoded)
+			}
+		})
+	}
+}
+
+func TestFetchPageAcceptsXHTML(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xhtml+xml; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`<?xml version="1.0"?><html xmlns="http://www.w3.org/1999/xhtml"><body><h1>XHTML</h1></body></html>`))
+	}))
+	defer server.Close()
+
+	result, err := fetchPage(server.URL, fetchOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.DeclaredType != "application/xhtml+xml" {
+		t.Errorf("expected declared type application/xhtml+xml, got %q", result.DeclaredType)
+	}
+}
+
+func TestFetchPageSniffsMislabeledHTML(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+	}{
+		{name: "text/plain", contentType: "text/plain"},
+		{name: "octet-stream", contentType: "application/octet-stream"},
+		{name: "missing header", contentType: ""},
+	}
+
+	for i, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Setting the header to nil stops net/http from sniffing and filling it in itself
+				w.Header()["Content-Type"] = nil
+				if tc.contentType != "" {
+					w.Header().Set("Content-Type", tc.contentType)
+				}
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte("<!DOCTYPE html><html><body><h1>Sniffed</h1></body></html>"))
+			}))
+			defer server.Close()
+
+			result, err := fetchPage(server.URL, fetchOptions{})
+			if err != nil {
+				t.Fatalf("Test %v - '%s' FAIL: unexpected error: %v", i, tc.name, err)
+			}
+			if result.DeclaredType != tc.contentType {
+				t.Errorf("Test %v - %s FAIL: expected declared type %q, got %q", i, tc.name, tc.contentType, result.DeclaredType)
+			}
+			if result.SniffedType != "text/html" {
+				t.Errorf("Test %v - %s FAIL: expected sniffed type text/html, got %q", i, tc.name, result.SniffedType)
+			}
+		})
+	}
+}
+
+func TestFetchPageStopsAtNonHTMLSniff(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.WriteHeader(http.StatusOK)
+		w.Write(append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, sniffLen)...))
+		w.(http.Flusher).Flush()
+		// The rest of a large download never arrives; fetchPage must not wait for it
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	_, err := fetchPage(server.URL, fetchOptions{Timeout: 2 * time.Second})
+	if err == nil {
+		t.Fatal("expected error for a binary body, got nil")
+	}
+	if !strings.Contains(err.Error(), "sniffed image/png") {
+		t.Errorf("expected a content type error, got %v", err)
+	}
+}
+
+func TestFetchPageLimitsBodySize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("<html><body>" + strings.Repeat("<p>text</p>", 100) + "</body></html>"))
+	}))
+	defer server.Close()
+
+	_, err := fetchPage(server.URL, fetchOptions{MaxBodyBytes: 100})
+	if err == nil {
+		t.Fatal("expected error for an oversized body, got nil")
+	}
+	if !strings.Contains(err.Error(), "larger than 100 bytes") {
+		t.Errorf("expected a size error, got %v", err)
+	}
+
+	if _, err := fetchPage(server.URL, fetchOptions{MaxBodyBytes: 10000}); err != nil {
+		t.Errorf("unexpected error under the limit: %v", err)
+	}
+}
+
+func TestFetchPageRejectsGenericNonHTML(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("just some notes, not a web page"))
+	}))
+	defer server.Close()
+
+	_, err := fetchPage(server.URL, fetchOptions{})
+	if err == nil {
+		t.Fatal("expected error for plain text body, got nil")
+	}
+	if !strings.Contains(err.Error(), "content type") {
+		t.Errorf("expected error to mention content type, got %v", err)
+	}
+}
+
+func TestFetchPageCustomAcceptedTypes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xhtml+xml")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`<html><body></body></html>`))
+	}))
+	defer server.Close()
+
+	_, err := fetchPage(server.URL, fetchOptions{AcceptedTypes: []string{"text/html"}})
+	if err == nil {
+		t.Fatal("expected XHTML to be rejected when only text/html is accepted, got nil")
+	}
+}
+
+func TestFetchPageConditionalRequest(t *testing.T) {
+	const etag = `"v1"`
+	const lastModified = "Wed, 01 May 2024 12:00:00 GMT"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", lastModified)
+		w.Write([]byte("<html><body><h1>Docs</h1></body></html>"))
+	}))
+	defer server.Close()
+
+	first, err := fetchPage(server.URL, fetchOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.NotModified || first.ETag != etag || first.LastModified != lastModified {
+		t.Fatalf("expected a full response with validators, got %+v", first)
+	}
+
+	second, err := fetchPage(server.URL, fetchOptions{ETag: first.ETag, LastModified: first.LastModified})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !second.NotModified || second.Body != "" {
+		t.Errorf("expected 304 Not Modified with no body, got %+v", second)
+	}
+	// The 304 didn't repeat the validators, so the ones sent are kept
+	if second.ETag != etag || second.LastModified != lastModified {
+		t.Errorf("expected validators to carry over, got %q and %q", second.ETag, second.LastModified)
+	}
+}
+
+func TestFetchPageSendsIfModifiedSince(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("If-Modified-Since")
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer server.Close()
+
+	result, err := fetchPage(server.URL, fetchOptions{LastModified: "Wed, 01 May 2024 12:00:00 GMT"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if received != "Wed, 01 May 2024 12:00:00 GMT" {
+		t.Errorf("expected If-Modified-Since to be sent, got %q", received)
+	}
+	if !result.NotModified {
+		t.Error("expected NotModified to be set")
+	}
+}
+
+func TestFetchPageReadsRobotsTag(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Header().Add("X-Robots-Tag", "noindex")
+		w.Header().Add("X-Robots-Tag", "nofollow")
+		w.Write([]byte("<html><body><h1>Draft</h1></body></html>"))
+	}))
+	defer server.Close()
+
+	result, err := fetchPage(server.URL, fetchOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.RobotsTag != "noindex, nofollow" || !hasNoIndex(result.RobotsTag) {
+		t.Errorf("expected both X-Robots-Tag headers, got %q", result.RobotsTag)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// asciiHost converts an internationalized host to its punycode form so "bücher.de" and
+// "xn--bcher-kva.de" compare equal. ASCII hosts are returned unchanged.
+func asciiHost(host string) string {
+	if isASCII(host) {
+		return host
+	}
+
+	hostname, port := host, ""
+	if i := strings.LastIndex(host, ":"); i >= 0 && !strings.Contains(host[i:], "]") {
+		hostname, port = host[:i], host[i:]
+	}
+	ascii, err := idna.Lookup.ToASCII(hostname)
+	if err != nil {
+		return host
+	}
+	return ascii + port
+}
+
+// sameHost reports whether two URLs are on the same host, comparing hosts case-insensitively in punycode form
+func sameHost(a, b *url.URL) bool {
+	return strings.EqualFold(asciiHost(a.Host), asciiHost(b.Host))
+}
+
+// displayURL turns a normalized URL back into a human-readable form, showing
+// punycode hosts in Unicode and percent-encoded UTF-8 path characters as text
+func displayURL(normalized string) string {
+	prefix, rest := "", normalized
+	if i := strings.Index(normalized, "://"); i >= 0 {
+		prefix, rest = normalized[:i+3], normalized[i+3:]
+	}
+
+	host, path := rest, ""
+	if i := strings.IndexAny(rest, "/?"); i >= 0 {
+		host, path = rest[:i], rest[i:]
+	}
+	if unicodeHost, err := idna.Display.ToUnicode(host); err == nil {
+		host = unicodeHost
+	}
+
+	return prefix + host + unescapeNonASCII(path)
+}
+
+// unescapeNonASCII decodes percent-escaped runs that form valid non-ASCII UTF-8,
+// leaving escaped ASCII such as "%2F" or "%20" untouched
+func unescapeNonASCII(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if !isHighEscape(s, i) {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+
+		// Collect the whole run of escaped high bytes, e.g. "%C3%A9"
+		start := i
+		var run []byte
+		for isHighEscape(s, i) {
+			run = append(run, unhex(s[i+1])<<4|unhex(s[i+2]))
+			i += 3
+		}
+		if utf8.Valid(run) {
+			b.Write(run)
+		} else {
+			b.WriteString(s[start:i])
+		}
+	}
+	return b.String()
+}
+
+// isHighEscape reports whether s has a percent-escape of a byte >= 0x80 at position i
+func isHighEscape(s string, i int) bool {
+	return i+2 < len(s) && s[i] == '%' && isHex(s[i+1]) && isHex(s[i+2]) && unhex(s[i+1]) >= 8
+}
+
+// isASCII reports whether s contains only ASCII characters
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestNormalizeURLInternationalized(t *testing.T) {
+	tests := []struct {
+		name     string
+		inputURL string
+		expected string
+	}{
+		{
+			name:     "unicode host",
+			inputURL: "https://bücher.de/katalog",
+			expected: "xn--bcher-kva.de/katalog",
+		},
+		{
+			name:     "punycode host",
+			inputURL: "https://xn--bcher-kva.de/katalog",
+			expected: "xn--bcher-kva.de/katalog",
+		},
+		{
+			name:     "unicode host with port",
+			inputURL: "https://bücher.de:8080/",
+			expected: "xn--bcher-kva.de:8080",
+		},
+		{
+			name:     "raw unicode path",
+			inputURL: "https://example.com/café",
+			expected: "example.com/caf%C3%A9",
+		},
+		{
+			name:     "lowercase percent-encoded path",
+			inputURL: "https://example.com/caf%c3%a9",
+			expected: "example.com/caf%C3%A9",
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := normalizeURL(tc.inputURL)
+			if err != nil {
+				t.Errorf("Test %v - '%s' FAIL: unexpected error: %v", i, tc.name, err)
+				return
+			}
+			if actual != tc.expected {
+				t.Errorf("Test %v - %s FAIL: expected URL: %v, actual: %v", i, tc.name, tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSameHost(t *testing.T) {
+	a, _ := url.Parse("https://Bücher.de/a")
+	b, _ := url.Parse("https://xn--bcher-kva.de/b")
+	c, _ := url.Parse("https://example.com/")
+
+	if !sameHost(a, b) {
+		t.Error("expected unicode and punycode hosts to match")
+	}
+	if sameHost(a, c) {
+		t.Error("expected different hosts not to match")
+	}
+}
+
+func TestDisplayURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		normalized string
+		expected   string
+	}{
+		{
+			name:       "punycode host and encoded path",
+			normalized: "xn--bcher-kva.de/caf%C3%A9",
+			expected:   "bücher.de/café",
+		},
+		{
+			name:       "keeps escaped ASCII",
+			normalized: "example.com/a%2Fb%20c",
+			expected:   "example.com/a%2Fb%20c",
+		},
+		{
+			name:       "keeps invalid UTF-8 escapes",
+			normalized: "example.com/%FF",
+			expected:   "example.com/%FF",
+		},
+		{
+			name:       "with scheme and query",
+			normalized: "https://xn--bcher-kva.de/suche?q=%C3%BC",
+			expected:   "https://bücher.de/suche?q=ü",
+		},
+		{
+			name:       "plain ASCII unchanged",
+			normalized: "blog.boot.dev/path",
+			expected:   "blog.boot.dev/path",
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if actual := displayURL(tc.normalized); actual != tc.expected {
+				t.Errorf("Test %v - %s FAIL: expected: %v, actual: %v", i, tc.name, tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// getIndexingFromHTML reads whether a page asks search engines not to index it, through
+// <meta name="robots"> or <meta name="googlebot">, and its absolute rel="canonical" URL
+func getIndexingFromHTML(htmlBody string, baseURL *url.URL) (noIndex bool, canonical string, err error) {
+	doc, err := html.Parse(strings.NewReader(htmlBody))
+	if err != nil {
+		return false, "", err
+	}
+	noIndex, canonical = getIndexingFromDoc(doc, baseURL)
+	return noIndex, canonical, nil
+}
+
+// getIndexingFromDoc is getIndexingFromHTML for an already parsed page
+func getIndexingFromDoc(root *html.Node, baseURL *url.URL) (noIndex bool, canonical string) {
+	doc := goquery.NewDocumentFromNode(root)
+
+	doc.Find("meta[name][content]").Each(func(_ int, s *goquery.Selection) {
+		name := strings.ToLower(s.AttrOr("name", ""))
+		if (name == "robots" || name == "googlebot") && hasNoIndex(s.AttrOr("content", "")) {
+			noIndex = true
+		}
+	})
+
+	doc.Find("link[rel][href]").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		for _, rel := range strings.Fields(strings.ToLower(s.AttrOr("rel", ""))) {
+			if rel != "canonical" {
+				continue
+			}
+			href, err := url.Parse(strings.TrimSpace(s.AttrOr("href", "")))
+			if err == nil {
+				canonical = baseURL.ResolveReference(href).String()
+			}
+			return false
+		}
+		return true
+	})
+
+	return noIndex, canonical
+}
+
+// hasNoIndex reports whether a robots directive list such as "noindex, follow" includes noindex.
+// Directives may be scoped to a user agent, as in the X-Robots-Tag header "googlebot: noindex".
+func hasNoIndex(directives string) bool {
+	for _, directive := range strings.Split(directives, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		if _, scoped, ok := strings.Cut(directive, ":"); ok {
+			directive = strings.TrimSpace(scoped)
+		}
+		if directive == "noindex" || directive == "none" {
+			return true
+		}
+	}
+	return false
+}
+
+// isCanonical reports whether a page is its own canonical, or declares none, comparing
+// URLs under the crawl's normalization policy
+func isCanonical(pageData PageData, policy normalizePolicy) bool {
+	if pageData.Canonical == "" {
+		return true
+	}
+	canonical, err := policy.normalize(pageData.Canonical)
+	if err != nil {
+		return true
+	}
+	self, err := policy.normalize(pageData.URL)
+	return err != nil || canonical == self
+}
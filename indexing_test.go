@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestGetIndexingFromHTML(t *testing.T) {
+	tests := []struct {
+		name          string
+		inputBody     string
+		wantNoIndex   bool
+		wantCanonical string
+	}{
+		{
+			name:      "indexable without canonical",
+			inputBody: `<html><head><meta name="robots" content="index, follow"></head></html>`,
+		},
+		{
+			name:        "robots noindex",
+			inputBody:   `<html><head><meta name="ROBOTS" content="NoIndex, follow"></head></html>`,
+			wantNoIndex: true,
+		},
+		{
+			name:        "robots none",
+			inputBody:   `<html><head><meta name="googlebot" content="none"></head></html>`,
+			wantNoIndex: true,
+		},
+		{
+			name:          "relative canonical",
+			inputBody:     `<html><head><link rel="canonical" href="/docs/intro"></head></html>`,
+			wantCanonical: "https://example.com/docs/intro",
+		},
+		{
+			name:      "other meta and link tags",
+			inputBody: `<html><head><meta name="description" content="noindex"><link rel="stylesheet" href="/app.css"></head></html>`,
+		},
+	}
+
+	baseURL, _ := url.Parse("https://example.com/docs/page")
+	for i, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			noIndex, canonical, err := getIndexingFromHTML(tc.inputBody, baseURL)
+			if err != nil {
+				t.Fatalf("Test %v - '%s' FAIL: unexpected error: %v", i, tc.name, err)
+			}
+			if noIndex != tc.wantNoIndex || canonical != tc.wantCanonical {
+				t.Errorf("Test %v - %s FAIL: expected (%v, %q), got (%v, %q)", i, tc.name, tc.wantNoIndex, tc.wantCanonical, noIndex, canonical)
+			}
+		})
+	}
+}
+
+func TestHasNoIndex(t *testing.T) {
+	tests := map[string]bool{
+		"noindex":                    true,
+		"noindex, nofollow":          true,
+		"googlebot: noindex":         true,
+		"nofollow":                   false,
+		"unavailable_after: 2025-01": false,
+		"":                           false,
+	}
+	for directives, expected := range tests {
+		if actual := hasNoIndex(directives); actual != expected {
+			t.Errorf("hasNoIndex(%q): expected %v, got %v", directives, expected, actual)
+		}
+	}
+}
+
+func TestIsCanonical(t *testing.T) {
+	keepQuery := normalizePolicy{Query: queryKeepAll}
+	tests := []struct {
+		name     string
+		page     PageData
+		policy   normalizePolicy
+		expected bool
+	}{
+		{"no canonical", PageData{URL: "https://example.com/a"}, normalizePolicy{}, true},
+		{"self canonical", PageData{URL: "https://example.com/a/", Canonical: "https://example.com/a"}, normalizePolicy{}, true},
+		{"points elsewhere", PageData{URL: "https://example.com/a?page=2", Canonical: "https://example.com/b"}, normalizePolicy{}, false},
+		{"query dropped by the crawl", PageData{URL: "https://example.com/a?page=2", Canonical: "https://example.com/a"}, normalizePolicy{}, true},
+		{"query kept by the crawl", PageData{URL: "https://example.com/a?page=2", Canonical: "https://example.com/a"}, keepQuery, false},
+		{"same query, other order", PageData{URL: "https://example.com/a?b=2&a=1", Canonical: "https://example.com/a?a=1&b=2"}, keepQuery, true},
+	}
+	for _, tc := range tests {
+		if actual := isCanonical(tc.page, tc.policy); actual != tc.expected {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// jsonPage is one entry of the JSON report
+type jsonPage struct {
+	NormalizedURL string   `json:"normalized_url"`
+	Page          PageData `json:"page"`
+}
+
+// jsonPolicy is the normalization policy of the crawl a JSON report came from, with the
+// parameters it learned, so later commands key URLs the same way the crawl did
+type jsonPolicy struct {
+	normalizePolicy
+	LearnedParams []ignoredParam `json:"learned_params,omitempty"`
+}
+
+// writeJSONReport writes every crawled page as JSON, one page per line, along with the
+// normalization policy, so a later crawl can revalidate against it and crawls can be compared
+func writeJSONReport(pages pageStore, policy normalizePolicy, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	saved := jsonPolicy{normalizePolicy: policy}
+	if policy.Learner != nil {
+		saved.LearnedParams = policy.Learner.ignoredParams()
+	}
+	policyData, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	if _, err := fmt.Fprintf(writer, "{\"policy\": %s,\n\"pages\": [", policyData); err != nil {
+		return err
+	}
+	first := true
+	err = pages.each(func(pageURL string, pageData PageData) error {
+		data, err := json.Marshal(jsonPage{NormalizedURL: pageURL, Page: pageData})
+		if err != nil {
+			return err
+		}
+		separator := ",\n"
+		if first {
+			separator, first = "\n", false
+		}
+		if _, err := writer.WriteString(separator); err != nil {
+			return err
+		}
+		_, err = writer.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := writer.WriteString("\n]}\n"); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// jsonLoadBatch is how many pages loadJSONReport decodes before storing them
+const jsonLoadBatch = 1000
+
+// loadJSONReport reads a report written by writeJSONReport into a page store, holding
+// only a batch of pages in memory at a time, and returns the crawl's normalization policy.
+// Reports from before the policy was saved are a bare array of pages and use the default policy.
+func loadJSONReport(filename string, pages pageStore) (normalizePolicy, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return normalizePolicy{}, err
+	}
+	defer file.Close()
+
+	// Decode one page at a time rather than the whole array at once
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	token, err := decoder.Token()
+	if err != nil {
+		return normalizePolicy{}, fmt.Errorf("reading %s: %w", filename, err)
+	}
+	if delim, ok := token.(json.Delim); ok && delim == '[' {
+		return normalizePolicy{}, loadJSONPages(decoder, filename, pages)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return normalizePolicy{}, fmt.Errorf("reading %s: not a JSON page report", filename)
+	}
+
+	var saved jsonPolicy
+	hasPolicy, hasPages := false, false
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return normalizePolicy{}, fmt.Errorf("reading %s: %w", filename, err)
+		}
+		switch token {
+		case "policy":
+			if err := decoder.Decode(&saved); err != nil {
+				return normalizePolicy{}, fmt.Errorf("reading %s: %w", filename, err)
+			}
+			hasPolicy = true
+		case "pages":
+			if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+				return normalizePolicy{}, fmt.Errorf("reading %s: pages is not an array", filename)
+			}
+			if err := loadJSONPages(decoder, filename, pages); err != nil {
+				return normalizePolicy{}, err
+			}
+			hasPages = true
+		default:
+			var skipped json.RawMessage
+			if err := decoder.Decode(&skipped); err != nil {
+				return normalizePolicy{}, fmt.Errorf("reading %s: %w", filename, err)
+			}
+		}
+	}
+	if !hasPolicy || !hasPages {
+		return normalizePolicy{}, fmt.Errorf("reading %s: not a JSON page report", filename)
+	}
+
+	policy := saved.normalizePolicy
+	if len(saved.LearnedParams) > 0 {
+		policy.Learner = newParamLearner()
+		policy.Learner.restore(learnerState{Ignored: saved.LearnedParams})
+	}
+	return policy, nil
+}
+
+// loadJSONPages stores the entries of a page array, once the decoder has read its opening bracket
+func loadJSONPages(decoder *json.Decoder, filename string, pages pageStore) error {
+	batch := make(map[string]PageData)
+	for decoder.More() {
+		var entry jsonPage
+		if err := decoder.Decode(&entry); err != nil {
+			return fmt.Errorf("reading %s: %w", filename, err)
+		}
+		batch[entry.NormalizedURL] = entry.Page
+		if len(batch) == jsonLoadBatch {
+			if err := pages.putAll(batch); err != nil {
+				return err
+			}
+			clear(batch)
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("reading %s: %w", filename, err)
+	}
+	return pages.putAll(batch)
+}
+
+// loadPreviousCrawl loads an earlier crawl's report into a store from open, keyed the way
+// policy keys this crawl's pages. Pages saved under other normalization rules are moved to
+// their keys under policy, keeping the first in URL order when several now share one.
+func loadPreviousCrawl(filename string, policy normalizePolicy, open func() (pageStore, error)) (pageStore, error) {
+	pages, err := open()
+	if err != nil {
+		return nil, err
+	}
+	savedPolicy, err := loadJSONReport(filename, pages)
+	if err != nil {
+		pages.close()
+		return nil, err
+	}
+	if samePolicy(savedPolicy, policy) {
+		return pages, nil
+	}
+
+	rekeyed, err := open()
+	if err != nil {
+		pages.close()
+		return nil, err
+	}
+	batch := make(map[string]PageData)
+	err = pages.each(func(pageURL string, pageData PageData) error {
+		key := pageURL
+		if normalized, err := policy.normalize(pageData.URL); err == nil && pageData.URL != "" {
+			key = normalized
+		}
+		if _, taken := batch[key]; taken {
+			return nil
+		}
+		if _, taken, err := rekeyed.get(key); err != nil || taken {
+			return err
+		}
+		batch[key] = pageData
+		if len(batch) == jsonLoadBatch {
+			if err := rekeyed.putAll(batch); err != nil {
+				return err
+			}
+			clear(batch)
+		}
+		return nil
+	})
+	if err == nil {
+		err = rekeyed.putAll(batch)
+	}
+	pages.close()
+	if err != nil {
+		rekeyed.close()
+		return nil, err
+	}
+	return rekeyed, nil
+}
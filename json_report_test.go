@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestJSONReportRoundTrip(t *testing.T) {
+	pages := map[string]PageData{
+		"example.com": {
+			URL:           "https://example.com",
+			H1:            "Home",
+			OutgoingLinks: []string{"https://example.com/docs"},
+			Outline:       getHeadingOutlineFromHTML("<h1>Home</h1>"),
+			ETag:          `"abc"`,
+			LastModified:  "Wed, 01 May 2024 12:00:00 GMT",
+			SimHash:       1<<63 + 5,
+		},
+		"example.com/docs": {URL: "https://example.com/docs", H1: "Docs"},
+		"example.com/down": {},
+	}
+
+	filename := filepath.Join(t.TempDir(), "pages.json")
+	if err := writeJSONReport(newMemoryStore(pages), normalizePolicy{}, filename); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loaded := newMemoryStore(nil)
+	if _, err := loadJSONReport(filename, loaded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.pages, pages) {
+		t.Errorf("expected %+v, got %+v", pages, loaded.pages)
+	}
+}
+
+func TestLoadJSONReportIntoTempBoltStore(t *testing.T) {
+	pages := make(map[string]PageData)
+	for i := 0; i < jsonLoadBatch+10; i++ {
+		pageURL := fmt.Sprintf("example.com/%d", i)
+		pages[pageURL] = PageData{URL: "https://" + pageURL, ETag: fmt.Sprintf(`"%d"`, i)}
+	}
+	filename := filepath.Join(t.TempDir(), "pages.json")
+	if err := writeJSONReport(newMemoryStore(pages), normalizePolicy{}, filename); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	store, err := openTempBoltStore()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := loadJSONReport(filename, store); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if store.count() != len(pages) {
+		t.Errorf("expected %d pages, got %d", len(pages), store.count())
+	}
+	if pageData, ok, _ := store.get("example.com/1005"); !ok || pageData.ETag != `"1005"` {
+		t.Errorf("expected a page from the last batch, got %+v", pageData)
+	}
+
+	path := store.db.Path()
+	if err := store.close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected the temporary store to be removed on close, got %v", err)
+	}
+}
+
+func TestJSONReportEmpty(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "pages.json")
+	if err := writeJSONReport(newMemoryStore(nil), normalizePolicy{}, filename); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loaded := newMemoryStore(nil)
+	if _, err := loadJSONReport(filename, loaded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loaded.count() != 0 {
+		t.Errorf("expected no pages, got %d", loaded.count())
+	}
+}
+
+func TestLoadJSONReportRejectsOtherJSON(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "other.json")
+	os.WriteFile(filename, []byte(`{"results": []}`), 0o644)
+
+	if _, err := loadJSONReport(filename, newMemoryStore(nil)); err == nil {
+		t.Error("expected an error for JSON that isn't a page report")
+	}
+}
+
+func TestJSONReportKeepsPolicy(t *testing.T) {
+	learner := newParamLearner()
+	learner.observe("https://example.com/list?view=grid", "hash-list")
+	learner.observe("https://example.com/list?view=table", "hash-list")
+	policy := normalizePolicy{Query: queryKeepListed, KeepParams: []string{"page", "view"}, StripIndex: true, Learner: learner}
+
+	filename := filepath.Join(t.TempDir(), "pages.json")
+	if err := writeJSONReport(newMemoryStore(nil), policy, filename); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	loaded, err := loadJSONReport(filename, newMemoryStore(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The loaded policy keys URLs the way the crawl did, learned parameters included
+	for rawURL, expected := range map[string]string{
+		"https://example.com/list?page=2&view=grid":                  "example.com/list?page=2",
+		"https://example.com/docs/index.html?view=grid&utm_source=x": "example.com/docs?view=grid",
+	} {
+		if got, _ := loaded.normalize(rawURL); got != expected {
+			t.Errorf("expected %s to normalize to %s, got %s", rawURL, expected, got)
+		}
+	}
+	if !samePolicy(policy, loaded) {
+		t.Errorf("expected %+v, got %+v", policy, loaded)
+	}
+}
+
+func TestLoadJSONReportWithoutPolicy(t *testing.T) {
+	// Reports from before the policy was saved are a bare array of pages
+	filename := filepath.Join(t.TempDir(), "pages.json")
+	os.WriteFile(filename, []byte(`[
+{"normalized_url":"example.com","page":{"URL":"https://example.com"}}
+]
+`), 0o644)
+
+	pages := newMemoryStore(nil)
+	policy, err := loadJSONReport(filename, pages)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pages.count() != 1 || !samePolicy(policy, normalizePolicy{}) {
+		t.Errorf("expected 1 page and the default policy, got %d pages and %+v", pages.count(), policy)
+	}
+}
+
+func TestLoadPreviousCrawlRekeysPages(t *testing.T) {
+	pages := map[string]PageData{
+		"http://example.com/a":  {URL: "http://example.com/a", ETag: `"http"`},
+		"https://example.com/a": {URL: "https://example.com/a", ETag: `"https"`},
+		"https://example.com/b": {URL: "https://example.com/b", ETag: `"b"`},
+	}
+	filename := filepath.Join(t.TempDir(), "pages.json")
+	if err := writeJSONReport(newMemoryStore(pages), normalizePolicy{KeepScheme: true}, filename); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	openMemory := func() (pageStore, error) { return newMemoryStore(nil), nil }
+
+	tests := []struct {
+		name     string
+		policy   normalizePolicy
+		expected map[string]string // key -> ETag
+	}{
+		{
+			name:     "same policy",
+			policy:   normalizePolicy{KeepScheme: true},
+			expected: map[string]string{"http://example.com/a": `"http"`, "https://example.com/a": `"https"`, "https://example.com/b": `"b"`},
+		},
+		{
+			name:     "other policy",
+			policy:   normalizePolicy{},
+			expected: map[string]string{"example.com/a": `"http"`, "example.com/b": `"b"`},
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			previous, err := loadPreviousCrawl(filename, tc.policy, openMemory)
+			if err != nil {
+				t.Fatalf("Test %v - '%s' FAIL: unexpected error: %v", i, tc.name, err)
+			}
+			defer previous.close()
+
+			actual := make(map[string]string)
+			previous.each(func(pageURL string, pageData PageData) error {
+				actual[pageURL] = pageData.ETag
+				return nil
+			})
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Errorf("Test %v - %s FAIL: expected %v, got %v", i, tc.name, tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// linkKind classifies an href by what following it would do
+type linkKind int
+
+const (
+	linkNavigable linkKind = iota // http(s) pages the crawler can follow
+	linkEmail                     // mailto: contact links
+	linkPhone                     // tel: contact links
+	linkScript                    // javascript: pseudo-links
+	linkOther                     // data: URIs and any other scheme
+)
+
+// pageLinks holds the hrefs found on a page, grouped by kind
+type pageLinks struct {
+	Navigable []string
+	Emails    []string
+	Phones    []string
+	Scripts   []string
+	Other     []string
+}
+
+// classifyLink reports the kind of a resolved link based on its scheme
+func classifyLink(u *url.URL) linkKind {
+	switch u.Scheme {
+	case "http", "https":
+		return linkNavigable
+	case "mailto":
+		return linkEmail
+	case "tel":
+		return linkPhone
+	case "javascript":
+		return linkScript
+	default:
+		return linkOther
+	}
+}
+
+// getLinksFromHTML extracts all anchor hrefs from the HTML and groups them by kind
+func getLinksFromHTML(htmlBody string, baseURL *url.URL) (pageLinks, error) {
+	doc, err := html.Parse(strings.NewReader(htmlBody))
+	if err != nil {
+		return pageLinks{}, err
+	}
+	return getLinksFromDoc(doc, baseURL), nil
+}
+
+// getLinksFromDoc is getLinksFromHTML for an already parsed page
+func getLinksFromDoc(root *html.Node, baseURL *url.URL) pageLinks {
+	doc := goquery.NewDocumentFromNode(root)
+
+	var links pageLinks
+	doc.Find("a[href]").Each(func(_ int, s *goquery.Selection) {
+		href, exists := s.Attr("href")
+		href = strings.TrimSpace(href)
+		if !exists || href == "" {
+			return
+		}
+
+		parsedURL, err := url.Parse(href)
+		if err != nil {
+			return
+		}
+
+		resolvedURL := baseURL.ResolveReference(parsedURL)
+		switch classifyLink(resolvedURL) {
+		case linkNavigable:
+			links.Navigable = append(links.Navigable, resolvedURL.String())
+		case linkEmail:
+			links.Emails = append(links.Emails, mailtoAddresses(resolvedURL)...)
+		case linkPhone:
+			if number := opaqueValue(resolvedURL); number != "" {
+				links.Phones = append(links.Phones, number)
+			}
+		case linkScript:
+			links.Scripts = append(links.Scripts, href)
+		case linkOther:
+			links.Other = append(links.Other, describeOtherLink(resolvedURL))
+		}
+	})
+
+	return links
+}
+
+// mailtoAddresses returns the recipient addresses of a mailto: link
+func mailtoAddresses(u *url.URL) []string {
+	var addresses []string
+	for _, addr := range strings.Split(opaqueValue(u), ",") {
+		addr = strings.TrimSpace(addr)
+		if addr != "" {
+			addresses = append(addresses, addr)
+		}
+	}
+	return addresses
+}
+
+// opaqueValue returns the unescaped scheme-specific part of a URL like mailto: or tel:
+func opaqueValue(u *url.URL) string {
+	value := u.Opaque
+	if value == "" {
+		// "tel://555" style links put the value in the host
+		value = u.Host + u.Path
+	}
+	if unescaped, err := url.PathUnescape(value); err == nil {
+		value = unescaped
+	}
+	return strings.TrimSpace(value)
+}
+
+// describeOtherLink returns a short form of a non-navigable link, dropping data: payloads
+func describeOtherLink(u *url.URL) string {
+	if u.Scheme == "data" {
+		mediaType, _, _ := strings.Cut(u.Opaque, ",")
+		return "data:" + mediaType
+	}
+	return u.String()
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestClassifyLink(t *testing.T) {
+	tests := []struct {
+		name     string
+		inputURL string
+		expected linkKind
+	}{
+		{
+			name:     "https page",
+			inputURL: "https://blog.boot.dev/about",
+			expected: linkNavigable,
+		},
+		{
+			name:     "http page",
+			inputURL: "http://blog.boot.dev",
+			expected: linkNavigable,
+		},
+		{
+			name:     "mailto link",
+			inputURL: "mailto:hello@boot.dev",
+			expected: linkEmail,
+		},
+		{
+			name:     "tel link",
+			inputURL: "tel:+1-555-0100",
+			expected: linkPhone,
+		},
+		{
+			name:     "javascript pseudo-link",
+			inputURL: "javascript:void(0)",
+			expected: linkScript,
+		},
+		{
+			name:     "data URI",
+			inputURL: "data:text/plain;base64,SGVsbG8=",
+			expected: linkOther,
+		},
+		{
+			name:     "ftp link",
+			inputURL: "ftp://files.boot.dev/file.txt",
+			expected: linkOther,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			parsedURL, err := url.Parse(tc.inputURL)
+			if err != nil {
+				t.Fatalf("Test %v - '%s' FAIL: couldn't parse URL: %v", i, tc.name, err)
+			}
+			if actual := classifyLink(parsedURL); actual != tc.expected {
+				t.Errorf("Test %v - %s FAIL: expected kind: %v, actual: %v", i, tc.name, tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestGetLinksFromHTMLGroupsByKind(t *testing.T) {
+	inputBody := `<html><body>
+		<a href="/about">About</a>
+		<a href="mailto:hello@boot.dev,support@boot.dev?subject=Hi">Email</a>
+		<a href="tel:+1%20555%200100">Call</a>
+		<a href="javascript:void(0)">Menu</a>
+		<a href="data:text/html;base64,PGgxPkhpPC9oMT4=">Data</a>
+		<a href="https://example.com/page">External</a>
+	</body></html>`
+
+	baseURL, _ := url.Parse("https://blog.boot.dev")
+	actual, err := getLinksFromHTML(inputBody, baseURL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := pageLinks{
+		Navigable: []string{"https://blog.boot.dev/about", "https://example.com/page"},
+		Emails:    []string{"hello@boot.dev", "support@boot.dev"},
+		Phones:    []string{"+1 555 0100"},
+		Scripts:   []string{"javascript:void(0)"},
+		Other:     []string{"data:text/html;base64"},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+}
+
+func TestGetURLsFromHTMLSkipsNonNavigable(t *testing.T) {
+	inputBody := `<html><body>
+		<a href="mailto:hello@boot.dev">Email</a>
+		<a href="tel:5550100">Call</a>
+		<a href="javascript:alert(1)">Alert</a>
+		<a href="/page1">Page 1</a>
+	</body></html>`
+
+	baseURL, _ := url.Parse("https://blog.boot.dev")
+	actual, err := getURLsFromHTML(inputBody, baseURL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"https://blog.boot.dev/page1"}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+}
@@ -1,84 +1,570 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
-	"strconv"
+	"os/signal"
+	"path/filepath"
+	"strings"
 	"sync"
+	"syscall"
+	"time"
 )
 
+// Exit codes shared by every subcommand
+const (
+	exitOK          = 0
+	exitCrawlError  = 1 // the crawl, a report or another operation failed
+	exitUsage       = 2 // bad flags or arguments
+	exitBrokenLinks = 3 // the crawl finished but found broken links
+)
+
+// command is a subcommand of the crawler
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+// commands lists the subcommands in the order the help text shows them
+var commands = []command{
+	{name: "crawl", summary: "crawl a site and write reports (the default)", run: runCrawl},
+	{name: "check-links", summary: "crawl a site and list its broken links", run: runCheckLinks},
+	{name: "diff", summary: "compare two crawls saved as pages.json", run: runDiff},
+	{name: "sitemap", summary: "write sitemap.xml from a crawl saved as pages.json", run: runSitemap},
+	{name: "serve", summary: "serve a directory of reports over HTTP", run: runServe},
+}
+
 func main() {
-	args := os.Args[1:]
+	os.Exit(run(os.Args[1:]))
+}
 
-	if len(args) < 1 {
-		fmt.Println("no website provided")
-		os.Exit(1)
+// run dispatches to a subcommand and returns the exit code. Arguments that start with a
+// flag or a URL are a crawl, so "crawler [flags] <url>" keeps working.
+func run(args []string) int {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return exitUsage
 	}
 
-	if len(args) > 3 {
-		fmt.Println("too many arguments provided")
-		fmt.Println("usage: crawler <url> [maxConcurrency] [maxPages]")
-		os.Exit(1)
+	name := args[0]
+	switch name {
+	case "help", "-h", "-help", "--help":
+		if len(args) > 1 {
+			if cmd, ok := findCommand(args[1]); ok {
+				return cmd.run([]string{"-help"})
+			}
+		}
+		usage(os.Stdout)
+		return exitOK
 	}
 
-	rawBaseURL := args[0]
-
-	// Default values
-	maxConcurrency := 10
-	maxPages := 0 // 0 means no limit
+	if cmd, ok := findCommand(name); ok {
+		return cmd.run(args[1:])
+	}
+	if strings.HasPrefix(name, "-") || strings.Contains(name, "://") {
+		return runCrawl(args)
+	}
+	fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+	usage(os.Stderr)
+	return exitUsage
+}
 
-	// Parse optional maxConcurrency
-	if len(args) >= 2 {
-		val, err := strconv.Atoi(args[1])
-		if err != nil || val < 1 {
-			fmt.Println("maxConcurrency must be a positive integer")
-			os.Exit(1)
+// findCommand looks up a subcommand by name
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
 		}
-		maxConcurrency = val
 	}
+	return command{}, false
+}
+
+// usage writes the top-level help text
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: crawler <command> [flags] [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "crawler help <command>" for a command's flags.`)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "exit codes:")
+	fmt.Fprintf(w, "  %d  success\n", exitOK)
+	fmt.Fprintf(w, "  %d  the crawl or another operation failed\n", exitCrawlError)
+	fmt.Fprintf(w, "  %d  bad flags or arguments\n", exitUsage)
+	fmt.Fprintf(w, "  %d  the crawl finished but found broken links\n", exitBrokenLinks)
+}
 
-	// Parse optional maxPages
-	if len(args) >= 3 {
-		val, err := strconv.Atoi(args[2])
-		if err != nil || val < 0 {
-			fmt.Println("maxPages must be a non-negative integer")
-			os.Exit(1)
+// parseArgs parses a subcommand's flags, which may come before or after its
+// positional arguments, and returns the positional arguments
+func parseArgs(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
 		}
-		maxPages = val
+		positional = append(positional, args[0])
+		args = args[1:]
 	}
+}
 
-	fmt.Printf("starting crawl of: %s\n", rawBaseURL)
-	fmt.Printf("  maxConcurrency: %d\n", maxConcurrency)
-	fmt.Printf("  maxPages: %d (0 = unlimited)\n", maxPages)
+// parseExitCode is the exit code for a flag parsing error: asking for help isn't a failure
+func parseExitCode(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	return exitUsage
+}
+
+// crawlOptions are the flags shared by every subcommand that crawls
+type crawlOptions struct {
+	concurrency        int
+	maxPages           int
+	depth              int
+	timeout            time.Duration
+	userAgent          string
+	checkAssets        bool
+	acceptTypes        string
+	keepScheme         bool
+	keepQuery          string
+	stripParams        string
+	learnParams        bool
+	normalize          string
+	skipDuplicateLinks bool
+	traps              *trapDetector
+}
+
+// addCrawlFlags registers the crawl options on flags
+func addCrawlFlags(flags *flag.FlagSet) *crawlOptions {
+	opts := &crawlOptions{traps: newTrapDetector()}
+	flags.IntVar(&opts.concurrency, "concurrency", 10, "how many pages to fetch at once")
+	flags.IntVar(&opts.maxPages, "max-pages", 0, "stop after this many pages (0 = no limit)")
+	flags.IntVar(&opts.depth, "depth", 0, "follow links at most this many clicks from the start page (0 = no limit)")
+	flags.DurationVar(&opts.timeout, "timeout", 30*time.Second, "give up on a request after this long, including its body (0 = never)")
+	flags.StringVar(&opts.userAgent, "user-agent", userAgent, "User-Agent header sent with every request")
+	flags.BoolVar(&opts.checkAssets, "check-assets", false, "send HEAD requests to record asset size and content type")
+	flags.StringVar(&opts.acceptTypes, "accept-types", strings.Join(defaultAcceptedTypes, ","), "comma-separated media types to parse as HTML")
+	flags.BoolVar(&opts.keepScheme, "keep-scheme", false, "treat http and https URLs as different pages")
+	flags.StringVar(&opts.keepQuery, "keep-query", "none", `query parameters to keep when deduping URLs: "none", "all" or a comma-separated list`)
+	flags.StringVar(&opts.stripParams, "strip-params", strings.Join(defaultTrackingParams, ","), "comma-separated tracking/session parameters to drop from kept queries (\"*\" suffix matches a prefix)")
+	flags.BoolVar(&opts.learnParams, "learn-params", true, "ignore query parameters whose values vary across pages with identical content")
+	flags.StringVar(&opts.normalize, "normalize", "", "comma-separated URL normalizations: lowercase-host, default-port, dot-segments, index, unreserved")
+	flags.BoolVar(&opts.skipDuplicateLinks, "skip-duplicate-links", false, "don't follow links from pages whose content duplicates an already crawled page")
+	flags.IntVar(&opts.traps.MaxPathDepth, "trap-max-path-depth", opts.traps.MaxPathDepth, "skip URLs with more path segments than this (0 = no limit)")
+	flags.IntVar(&opts.traps.MaxURLLength, "trap-max-url-length", opts.traps.MaxURLLength, "skip URLs longer than this (0 = no limit)")
+	flags.IntVar(&opts.traps.MaxSegmentRepeats, "trap-max-segment-repeats", opts.traps.MaxSegmentRepeats, "skip URLs repeating a path segment more than this (0 = no limit)")
+	flags.IntVar(&opts.traps.MaxQueryVariants, "trap-max-query-variants", opts.traps.MaxQueryVariants, "skip query variants of a path beyond this many (0 = no limit)")
+	flags.IntVar(&opts.traps.MaxPagesPerDir, "trap-max-pages-per-dir", opts.traps.MaxPagesPerDir, "skip URLs in a directory beyond this many (0 = no limit)")
+	return opts
+}
+
+// newConfig checks the options and builds the crawler state for a crawl of rawBaseURL
+// that stores its pages in pages. Every error is a problem with the arguments.
+func (opts *crawlOptions) newConfig(rawBaseURL string, pages pageStore) (*config, error) {
+	switch {
+	case opts.concurrency < 1:
+		return nil, fmt.Errorf("-concurrency must be at least 1")
+	case opts.maxPages < 0:
+		return nil, fmt.Errorf("-max-pages must not be negative")
+	case opts.depth < 0:
+		return nil, fmt.Errorf("-depth must not be negative")
+	case opts.timeout < 0:
+		return nil, fmt.Errorf("-timeout must not be negative")
+	}
 
 	baseURL, err := url.Parse(rawBaseURL)
 	if err != nil {
-		fmt.Printf("error parsing base URL: %v\n", err)
-		os.Exit(1)
+		return nil, fmt.Errorf("error parsing base URL: %v", err)
+	}
+	if (baseURL.Scheme != "http" && baseURL.Scheme != "https") || baseURL.Host == "" {
+		return nil, fmt.Errorf("%s is not an absolute http or https URL", rawBaseURL)
+	}
+
+	normalizer, err := newNormalizePolicy(opts.keepScheme, opts.keepQuery, opts.stripParams, opts.normalize)
+	if err != nil {
+		return nil, err
+	}
+	if opts.learnParams && normalizer.Query != queryDropAll {
+		normalizer.Learner = newParamLearner()
 	}
 
 	cfg := &config{
-		pages:              make(map[string]PageData),
+		pages:              pages,
 		baseURL:            baseURL,
 		mu:                 &sync.Mutex{},
-		concurrencyControl: make(chan struct{}, maxConcurrency),
+		concurrencyControl: make(chan struct{}, opts.concurrency),
 		wg:                 &sync.WaitGroup{},
-		maxPages:           maxPages,
+		maxPages:           opts.maxPages,
+		maxDepth:           opts.depth,
+		normalizer:         normalizer,
+		traps:              opts.traps,
+		skipDuplicateLinks: opts.skipDuplicateLinks,
+	}
+	cfg.fetchOpts.AcceptedTypes = splitList(opts.acceptTypes)
+	cfg.fetchOpts.UserAgent = opts.userAgent
+	cfg.fetchOpts.Timeout = opts.timeout
+	if opts.checkAssets {
+		cfg.assetChecker = newAssetChecker()
+		cfg.assetChecker.client.Timeout = opts.timeout
+		cfg.assetChecker.userAgent = opts.userAgent
+	}
+	return cfg, nil
+}
+
+// startPageError reports why the start page couldn't be crawled, or nil if it was
+func (cfg *config) startPageError() error {
+	normalizedURL, err := cfg.normalizer.normalize(cfg.baseURL.String())
+	if err != nil {
+		return err
+	}
+	pageData, ok, err := cfg.pages.get(normalizedURL)
+	if err != nil {
+		return err
+	}
+	if ok && pageData.FetchError != "" {
+		return fmt.Errorf("could not crawl %s: %s", cfg.baseURL, pageData.FetchError)
+	}
+	return nil
+}
+
+// runCrawl implements "crawler crawl [flags] <url>" and returns the exit code
+func runCrawl(args []string) int {
+	flags := flag.NewFlagSet("crawl", flag.ContinueOnError)
+	opts := addCrawlFlags(flags)
+	outputDir := flags.String("output", ".", "directory to write the reports into")
+	reportFormatName := flags.String("format", "csv", `format of the main page report: "csv" or "tsv"`)
+	columns := flags.String("columns", "", "comma-separated columns for the main report and -stream, in order (empty = all)")
+	listSeparator := flags.String("list-separator", ";", "separator between list values such as links in one cell; occurrences inside a value are percent-encoded")
+	longFormat := flags.Bool("long", false, "write one row per list value (link, image, ...) instead of joining them into one cell")
+	noHeader := flags.Bool("no-header", false, "leave the header row out of the main report and -stream")
+	streamFile := flags.String("stream", "", "write each page to this file as soon as it is crawled: CSV, or JSON lines for .jsonl/.ndjson (empty = no stream)")
+	streamFlushInterval := flags.Duration("stream-flush-interval", 5*time.Second, "how often to flush -stream to disk (0 = after every page)")
+	nearDuplicateThreshold := flags.Float64("near-duplicate-threshold", 0.9, "minimum SimHash similarity (0-1) for pages to be clustered as near-duplicates (0 = no report)")
+	stateDir := flags.String("state-dir", "", "directory to save crawl checkpoints in (empty = no checkpoints)")
+	checkpointInterval := flags.Duration("checkpoint-interval", 30*time.Second, "how often to save a checkpoint to -state-dir")
+	resume := flags.Bool("resume", false, "continue the crawl saved in -state-dir instead of starting over")
+	pageStorePath := flags.String("page-store", "", "keep crawled pages in this database file instead of memory, for sites too large for RAM")
+	sqlitePath := flags.String("sqlite", "", "also write the crawl to this SQLite database (empty = no database)")
+	since := flags.String("since", "", "pages.json from an earlier crawl; pages it saw are fetched conditionally and reused if unchanged")
+	sitemapDir := flags.String("sitemap", "", "write sitemap.xml for the indexable pages into this directory (empty = no sitemap)")
+	sitemapURL := flags.String("sitemap-url", "", "URL the sitemap files will be served from, for the sitemap index (default: the site root)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: crawler crawl [flags] <url>")
+		fmt.Fprintln(flags.Output(), "Crawls the site and writes reports. Exits 3 if any crawled link is broken.")
+		flags.PrintDefaults()
+	}
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return parseExitCode(err)
+	}
+	if len(positional) != 1 {
+		if len(positional) > 1 {
+			fmt.Fprintln(flags.Output(), "expected one URL; set the concurrency and page limit with -concurrency and -max-pages")
+		}
+		flags.Usage()
+		return exitUsage
+	}
+	rawBaseURL := positional[0]
+
+	if *resume && *stateDir == "" {
+		fmt.Fprintln(os.Stderr, "-resume requires -state-dir")
+		return exitUsage
+	}
+	if *nearDuplicateThreshold < 0 || *nearDuplicateThreshold > 1 {
+		fmt.Fprintln(os.Stderr, "-near-duplicate-threshold must be between 0 and 1")
+		return exitUsage
+	}
+
+	format := defaultReportFormat()
+	format.Columns, err = parseReportColumns(*columns)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	if *listSeparator == "" {
+		fmt.Fprintln(os.Stderr, "-list-separator must not be empty")
+		return exitUsage
+	}
+	format.ListSeparator = *listSeparator
+	format.Long = *longFormat
+	format.NoHeader = *noHeader
+	reportName := "report.csv"
+	switch *reportFormatName {
+	case "csv":
+	case "tsv":
+		format.Delimiter = '\t'
+		reportName = "report.tsv"
+	default:
+		fmt.Fprintf(os.Stderr, "unknown -format %q: use csv or tsv\n", *reportFormatName)
+		return exitUsage
+	}
+
+	cfg, err := opts.newConfig(rawBaseURL, newMemoryStore(nil))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	if err := os.MkdirAll(*outputDir, 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "error creating output directory: %v\n", err)
+		return exitCrawlError
+	}
+	output := func(name string) string {
+		return filepath.Join(*outputDir, name)
+	}
+
+	if *pageStorePath != "" {
+		cfg.pages, err = openBoltStore(*pageStorePath, *resume)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error opening page store: %v\n", err)
+			return exitCrawlError
+		}
+	}
+	defer cfg.pages.close()
+	if *since != "" {
+		openPrevious := func() (pageStore, error) { return newMemoryStore(nil), nil }
+		if *pageStorePath != "" {
+			// A crawl too big for RAM keeps the previous crawl on disk as well
+			openPrevious = func() (pageStore, error) { return openTempBoltStore() }
+		}
+		previous, err := loadPreviousCrawl(*since, cfg.normalizer, openPrevious)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error loading previous crawl: %v\n", err)
+			return exitCrawlError
+		}
+		defer previous.close()
+		cfg.previous = previous
+	}
+	if *streamFile != "" {
+		cfg.stream, err = openPageStream(*streamFile, format, *resume, *streamFlushInterval)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error opening stream: %v\n", err)
+			return exitCrawlError
+		}
+	}
+
+	fmt.Printf("starting crawl of: %s\n", rawBaseURL)
+	fmt.Printf("  concurrency: %d\n", opts.concurrency)
+	fmt.Printf("  max pages: %d (0 = unlimited)\n", opts.maxPages)
+	fmt.Printf("  depth: %d (0 = unlimited)\n", opts.depth)
+
+	startedAt := time.Now()
+	if *resume {
+		cp, err := loadCheckpoint(*stateDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error loading checkpoint: %v\n", err)
+			return exitCrawlError
+		}
+		if err := cfg.resume(cp); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitCrawlError
+		}
+		queued := cfg.pages.queueLen()
+		fmt.Printf("resuming from checkpoint saved %s: %d pages, %d queued URLs\n", cp.SavedAt.Format(time.RFC3339), cfg.pages.count()-queued, queued)
+	} else if err := cfg.enqueue(rawBaseURL, 0); err != nil {
+		fmt.Fprintf(os.Stderr, "error queueing start page: %v\n", err)
+		return exitCrawlError
 	}
 
-	cfg.wg.Add(1)
-	go cfg.crawlPage(rawBaseURL)
-	cfg.wg.Wait()
+	stopCheckpoints := make(chan struct{})
+	if *stateDir != "" {
+		go cfg.checkpointEvery(*stateDir, *checkpointInterval, stopCheckpoints)
+
+		// Save the crawl before exiting on Ctrl-C or a kill so it can be resumed
+		interrupted := make(chan os.Signal, 1)
+		signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(interrupted)
+		go func() {
+			<-interrupted
+			if err := cfg.saveCheckpoint(*stateDir); err != nil {
+				fmt.Fprintf(os.Stderr, "\nerror saving checkpoint: %v\n", err)
+				os.Exit(exitCrawlError)
+			}
+			fmt.Printf("\ncrawl interrupted; resume with -resume -state-dir %s\n", *stateDir)
+			os.Exit(130)
+		}()
+	}
+
+	if err := cfg.crawl(); err != nil {
+		fmt.Fprintf(os.Stderr, "error crawling: %v\n", err)
+		return exitCrawlError
+	}
+	finishedAt := time.Now()
+	close(stopCheckpoints)
+	if cfg.stream != nil {
+		if err := cfg.stream.close(); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing stream: %v\n", err)
+			return exitCrawlError
+		}
+		fmt.Printf("Pages streamed to: %s\n", *streamFile)
+	}
+	if *stateDir != "" {
+		if err := cfg.saveCheckpoint(*stateDir); err != nil {
+			fmt.Fprintf(os.Stderr, "error saving checkpoint: %v\n", err)
+			return exitCrawlError
+		}
+	}
 
 	fmt.Println("\n--- Crawl Results ---")
-	fmt.Printf("Crawled %d pages\n", len(cfg.pages))
+	summary, err := summarizeCrawl(cfg.pages, cfg.baseURL, cfg.normalizer, startedAt, finishedAt)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error summarizing crawl: %v\n", err)
+		return exitCrawlError
+	}
+	printSummary(os.Stdout, summary)
+	if cfg.previous != nil {
+		unchanged := 0
+		cfg.pages.each(func(_ string, pageData PageData) error {
+			if pageData.NotModified {
+				unchanged++
+			}
+			return nil
+		})
+		fmt.Printf("%d pages unchanged since the previous crawl\n", unchanged)
+	}
+	if cfg.normalizer.Learner != nil {
+		for _, p := range cfg.normalizer.Learner.ignoredParams() {
+			fmt.Printf("Ignored query parameter %q on %s: %s and %s have the same content\n", p.Name, p.Path, p.FirstURL, p.OtherURL)
+		}
+	}
 
 	// Write CSV report
-	reportFile := "report.csv"
-	if err := writeCSVReport(cfg.pages, reportFile); err != nil {
-		fmt.Printf("error writing CSV report: %v\n", err)
-		os.Exit(1)
+	reportFile := output(reportName)
+	if err := writeCSVReportWithFormat(cfg.pages, format, reportFile); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing CSV report: %v\n", err)
+		return exitCrawlError
 	}
 	fmt.Printf("Report written to: %s\n", reportFile)
+
+	summaryFile := output("summary.json")
+	if err := writeSummaryJSON(summary, summaryFile); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing summary: %v\n", err)
+		return exitCrawlError
+	}
+	fmt.Printf("Summary written to: %s\n", summaryFile)
+
+	jsonReportFile := output("pages.json")
+	if err := writeJSONReport(cfg.pages, cfg.normalizer, jsonReportFile); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing JSON report: %v\n", err)
+		return exitCrawlError
+	}
+	fmt.Printf("JSON report written to: %s\n", jsonReportFile)
+
+	htmlReportFile := output("report.html")
+	if err := writeHTMLReport(cfg.pages, rawBaseURL, cfg.normalizer, htmlReportFile); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing HTML report: %v\n", err)
+		return exitCrawlError
+	}
+	fmt.Printf("HTML report written to: %s\n", htmlReportFile)
+
+	traps := cfg.traps.suspectedTraps()
+	fmt.Printf("\n--- Suspected Traps ---\n")
+	fmt.Printf("Skipped %d suspicious URLs\n", len(traps))
+	trapReportFile := output("traps.csv")
+	if err := writeTrapReport(traps, trapReportFile); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing trap report: %v\n", err)
+		return exitCrawlError
+	}
+	fmt.Printf("Trap report written to: %s\n", trapReportFile)
+
+	duplicateReportFile := output("duplicates.csv")
+	if err := writeDuplicateReport(cfg.pages, duplicateReportFile); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing duplicate report: %v\n", err)
+		return exitCrawlError
+	}
+	fmt.Printf("Duplicate content report written to: %s\n", duplicateReportFile)
+
+	if *nearDuplicateThreshold > 0 {
+		nearDuplicateReportFile := output("near_duplicates.csv")
+		if err := writeNearDuplicateReport(cfg.pages, *nearDuplicateThreshold, nearDuplicateReportFile); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing near-duplicate report: %v\n", err)
+			return exitCrawlError
+		}
+		fmt.Printf("Near-duplicate report written to: %s\n", nearDuplicateReportFile)
+	}
+
+	assetReportFile := output("assets.csv")
+	if err := writeAssetReport(cfg.pages, assetReportFile); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing asset report: %v\n", err)
+		return exitCrawlError
+	}
+	fmt.Printf("Asset report written to: %s\n", assetReportFile)
+
+	accessibilityReportFile := output("accessibility.csv")
+	if err := writeAccessibilityReport(cfg.pages, accessibilityReportFile); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing accessibility report: %v\n", err)
+		return exitCrawlError
+	}
+	fmt.Printf("Accessibility report written to: %s\n", accessibilityReportFile)
+
+	structureReportFile := output("structure.csv")
+	if err := writeStructureReport(cfg.pages, structureReportFile); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing structure report: %v\n", err)
+		return exitCrawlError
+	}
+	fmt.Printf("Structure report written to: %s\n", structureReportFile)
+
+	schemaTypesReportFile := output("schema_types.csv")
+	if err := writeSchemaTypesReport(cfg.pages, schemaTypesReportFile); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing schema types report: %v\n", err)
+		return exitCrawlError
+	}
+	fmt.Printf("Schema types report written to: %s\n", schemaTypesReportFile)
+
+	schemaIssuesReportFile := output("schema_issues.csv")
+	if err := writeSchemaIssuesReport(cfg.pages, schemaIssuesReportFile); err != nil {
+		fmt.Fprintf(os.Stderr, "error writing schema issues report: %v\n", err)
+		return exitCrawlError
+	}
+	fmt.Printf("Schema issues report written to: %s\n", schemaIssuesReportFile)
+
+	if *sitemapDir != "" {
+		siteURL := *sitemapURL
+		if siteURL == "" {
+			siteURL = cfg.baseURL.Scheme + "://" + cfg.baseURL.Host + "/"
+		}
+		files, err := writeSitemaps(cfg.pages, cfg.normalizer, *sitemapDir, siteURL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error writing sitemap: %v\n", err)
+			return exitCrawlError
+		}
+		fmt.Printf("Sitemap written to: %s\n", strings.Join(files, ", "))
+	}
+
+	if *sqlitePath != "" {
+		crawl := crawlRun{BaseURL: rawBaseURL, StartedAt: startedAt, FinishedAt: finishedAt}
+		if err := writeSQLiteReport(cfg.pages, crawl, cfg.normalizer, *sqlitePath); err != nil {
+			fmt.Fprintf(os.Stderr, "error writing SQLite report: %v\n", err)
+			return exitCrawlError
+		}
+		fmt.Printf("SQLite report written to: %s\n", *sqlitePath)
+	}
+
+	startErr := cfg.startPageError()
+	brokenLinks, err := findBrokenLinks(cfg.pages, cfg.normalizer)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error finding broken links: %v\n", err)
+		return exitCrawlError
+	}
+
+	if startErr != nil {
+		fmt.Fprintln(os.Stderr, startErr)
+		return exitCrawlError
+	}
+	if len(brokenLinks) > 0 {
+		fmt.Printf("\nFound %d broken links; see %s\n", len(brokenLinks), htmlReportFile)
+		return exitBrokenLinks
+	}
+	return exitOK
 }
\ No newline at end of file
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// createLinkTestServer serves a home page linking to a working page and, if broken is set, to a missing one
+func createLinkTestServer(broken bool) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		links := `<a href="/ok">OK</a>`
+		if broken {
+			links += `<a href="/missing">Missing</a>`
+		}
+		w.Write([]byte(`<html><body><h1>Home</h1>` + links + `</body></html>`))
+	})
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><h1>OK</h1><a href="/ok/deeper">Deeper</a></body></html>`))
+	})
+	mux.HandleFunc("/ok/deeper", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><h1>Deeper</h1></body></html>`))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestRunExitCodes(t *testing.T) {
+	clean := createLinkTestServer(false)
+	defer clean.Close()
+	broken := createLinkTestServer(true)
+	defer broken.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	downURL := down.URL
+	down.Close()
+
+	tests := []struct {
+		name     string
+		args     []string
+		reports  bool // writes reports, so they go to a temporary directory
+		expected int
+	}{
+		{name: "no arguments", args: nil, expected: exitUsage},
+		{name: "help", args: []string{"--help"}, expected: exitOK},
+		{name: "command help", args: []string{"help", "crawl"}, expected: exitOK},
+		{name: "unknown command", args: []string{"crawll"}, expected: exitUsage},
+		{name: "unknown flag", args: []string{"crawl", "-nope", clean.URL}, expected: exitUsage},
+		{name: "old positional limits", args: []string{clean.URL, "5", "10"}, expected: exitUsage},
+		{name: "bad concurrency", args: []string{"crawl", "-concurrency", "0", clean.URL}, expected: exitUsage},
+		{name: "relative URL", args: []string{"check-links", "example.com"}, expected: exitUsage},
+		{name: "bad format", args: []string{"crawl", "-format", "xml", clean.URL}, expected: exitUsage},
+		{name: "clean crawl", args: []string{"crawl", clean.URL}, reports: true, expected: exitOK},
+		{name: "crawl without command", args: []string{"-max-pages", "5", clean.URL}, reports: true, expected: exitOK},
+		{name: "crawl with broken links", args: []string{"crawl", broken.URL}, reports: true, expected: exitBrokenLinks},
+		{name: "check clean links", args: []string{"check-links", clean.URL}, expected: exitOK},
+		{name: "check broken links", args: []string{"check-links", broken.URL, "-concurrency", "2"}, expected: exitBrokenLinks},
+		{name: "site down", args: []string{"check-links", downURL}, expected: exitCrawlError},
+		{name: "missing diff input", args: []string{"diff", "nope.json", "nope.json"}, expected: exitCrawlError},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			args := tc.args
+			if tc.reports {
+				args = append(append([]string(nil), args...), "-output", t.TempDir())
+			}
+			if code := run(args); code != tc.expected {
+				t.Errorf("run(%q) = %d, want %d", tc.args, code, tc.expected)
+			}
+		})
+	}
+}
+
+func TestRunCrawlWritesToOutputDir(t *testing.T) {
+	server := createLinkTestServer(false)
+	defer server.Close()
+
+	dir := filepath.Join(t.TempDir(), "reports")
+	if code := run([]string{"crawl", "-output", dir, "-format", "tsv", "-depth", "1", server.URL}); code != exitOK {
+		t.Fatalf("crawl exited %d", code)
+	}
+	for _, name := range []string{"report.tsv", "pages.json", "report.html", "summary.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %s in the output directory: %v", name, err)
+		}
+	}
+
+	// -depth 1 stops before /ok/deeper
+	pages := newMemoryStore(nil)
+	if _, err := loadJSONReport(filepath.Join(dir, "pages.json"), pages); err != nil {
+		t.Fatal(err)
+	}
+	if pages.count() != 2 {
+		t.Errorf("crawled %d pages with -depth 1, want 2", pages.count())
+	}
+}
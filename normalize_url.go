@@ -1,19 +1,269 @@
 package main
 
 import (
+	"fmt"
 	"net/url"
+	pathpkg "path"
+	"sort"
 	"strings"
 )
 
+// queryMode controls which query parameters survive normalization
+type queryMode int
+
+const (
+	queryDropAll    queryMode = iota // strip the whole query string
+	queryKeepAll                     // keep every parameter, sorted by key
+	queryKeepListed                  // keep only the parameters named in KeepParams, sorted by key
+)
+
+// queryModeNames are the names of query modes in saved policies
+var queryModeNames = map[queryMode]string{
+	queryDropAll:    "none",
+	queryKeepAll:    "all",
+	queryKeepListed: "listed",
+}
+
+func (m queryMode) MarshalText() ([]byte, error) {
+	name, ok := queryModeNames[m]
+	if !ok {
+		return nil, fmt.Errorf("unknown query mode %d", int(m))
+	}
+	return []byte(name), nil
+}
+
+func (m *queryMode) UnmarshalText(text []byte) error {
+	for mode, name := range queryModeNames {
+		if name == string(text) {
+			*m = mode
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown query mode %q", text)
+}
+
+// indexFiles are directory index documents that StripIndex removes from the end of a path
+var indexFiles = map[string]bool{
+	"index.html":   true,
+	"index.htm":    true,
+	"index.php":    true,
+	"default.htm":  true,
+	"default.aspx": true,
+}
+
+// defaultPorts maps schemes to the port RemoveDefaultPort strips
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// normalizePolicy controls how URLs are reduced to the keys used to dedupe pages.
+// The zero value behaves like normalizeURL.
+type normalizePolicy struct {
+	KeepScheme          bool          `json:"keep_scheme,omitempty"`           // keep "https://" so http and https pages are distinct
+	Query               queryMode     `json:"query"`                           // which query parameters to keep
+	KeepParams          []string      `json:"keep_params,omitempty"`           // parameters kept by queryKeepListed
+	LowercaseHost       bool          `json:"lowercase_host,omitempty"`        // "Blog.Boot.dev" -> "blog.boot.dev"
+	RemoveDefaultPort   bool          `json:"remove_default_port,omitempty"`   // "boot.dev:443" -> "boot.dev" for https
+	CollapseDotSegments bool          `json:"collapse_dot_segments,omitempty"` // "/a/./b/../c" -> "/a/c"
+	StripIndex          bool          `json:"strip_index,omitempty"`           // "/docs/index.html" -> "/docs"
+	DecodeUnreserved    bool          `json:"decode_unreserved,omitempty"`     // keep the path percent-encoded, decoding only unreserved characters ("%7E" -> "~")
+	StripParams         []string      `json:"strip_params,omitempty"`          // tracking and session parameters removed from kept queries and path parameters
+	Learner             *paramLearner `json:"-"`                               // when set, also removes parameters learned not to affect content
+}
+
 // normalizeURL removes the scheme, query parameters, and fragments from a URL, but retains "www." if present.
 func normalizeURL(input string) (string, error) {
+	return normalizePolicy{}.normalize(input)
+}
+
+// newNormalizePolicy builds a policy from command-line style settings:
+// keepQuery is "none", "all" or a comma-separated list of parameters, stripParams is a
+// comma-separated list of parameters to remove, and options is a comma-separated list of
+// lowercase-host, default-port, dot-segments, index and unreserved.
+func newNormalizePolicy(keepScheme bool, keepQuery, stripParams, options string) (normalizePolicy, error) {
+	policy := normalizePolicy{KeepScheme: keepScheme, StripParams: splitList(stripParams)}
+
+	switch keepQuery {
+	case "", "none":
+		policy.Query = queryDropAll
+	case "all":
+		policy.Query = queryKeepAll
+	default:
+		policy.Query = queryKeepListed
+		policy.KeepParams = splitList(keepQuery)
+	}
+
+	for _, option := range splitList(options) {
+		switch option {
+		case "lowercase-host":
+			policy.LowercaseHost = true
+		case "default-port":
+			policy.RemoveDefaultPort = true
+		case "dot-segments":
+			policy.CollapseDotSegments = true
+		case "index":
+			policy.StripIndex = true
+		case "unreserved":
+			policy.DecodeUnreserved = true
+		default:
+			return normalizePolicy{}, fmt.Errorf("unknown normalization option: %s", option)
+		}
+	}
+
+	return policy, nil
+}
+
+// normalize reduces a URL to its dedupe key according to the policy
+func (p normalizePolicy) normalize(input string) (string, error) {
 	parsedURL, err := url.Parse(input)
 	if err != nil {
 		return "", err
 	}
 
-	host := parsedURL.Host
-	path := strings.TrimSuffix(parsedURL.Path, "/")
+	host := asciiHost(parsedURL.Host)
+	if p.LowercaseHost {
+		host = strings.ToLower(host)
+	}
+	if p.RemoveDefaultPort {
+		if port := parsedURL.Port(); port != "" && defaultPorts[parsedURL.Scheme] == port {
+			host = strings.TrimSuffix(host, ":"+port)
+		}
+	}
 
-	return host + path, nil
+	// Keep the path in URI form so raw Unicode and percent-encoded links produce the same key
+	path := uppercaseEscapes(parsedURL.EscapedPath())
+	if p.DecodeUnreserved {
+		path = decodeUnreserved(path)
+	}
+	if len(p.StripParams) > 0 {
+		path = stripPathParams(path, p.StripParams)
+	}
+	if p.CollapseDotSegments && path != "" {
+		trailingSlash := strings.HasSuffix(path, "/")
+		path = pathpkg.Clean("/" + path)
+		if trailingSlash && path != "/" {
+			path += "/"
+		}
+	}
+	if p.StripIndex {
+		if dir, file := pathpkg.Split(path); indexFiles[strings.ToLower(file)] {
+			path = dir
+		}
+	}
+	path = strings.TrimSuffix(path, "/")
+
+	normalized := host + path
+	if p.KeepScheme && parsedURL.Scheme != "" {
+		normalized = parsedURL.Scheme + "://" + normalized
+	}
+	if query := p.normalizeQuery(learnerScope(parsedURL), parsedURL.Query()); query != "" {
+		normalized += "?" + query
+	}
+
+	return normalized, nil
+}
+
+// normalizeQuery returns the kept query parameters of a URL on scope (its host and path) encoded with sorted keys
+func (p normalizePolicy) normalizeQuery(scope string, values url.Values) string {
+	for key := range values {
+		if matchesParam(key, p.StripParams) || (p.Learner != nil && p.Learner.isIgnored(scope, key)) {
+			delete(values, key)
+		}
+	}
+
+	switch p.Query {
+	case queryKeepAll:
+		for key := range values {
+			sort.Strings(values[key])
+		}
+		return values.Encode()
+	case queryKeepListed:
+		kept := url.Values{}
+		for _, key := range p.KeepParams {
+			if vals, ok := values[key]; ok {
+				kept[key] = append([]string(nil), vals...)
+				sort.Strings(kept[key])
+			}
+		}
+		return kept.Encode()
+	default:
+		return ""
+	}
+}
+
+// decodeUnreserved decodes percent-escapes of unreserved characters (RFC 3986 section 2.3)
+func decodeUnreserved(escaped string) string {
+	var b strings.Builder
+	for i := 0; i < len(escaped); i++ {
+		if escaped[i] == '%' && i+2 < len(escaped) && isHex(escaped[i+1]) && isHex(escaped[i+2]) {
+			c := unhex(escaped[i+1])<<4 | unhex(escaped[i+2])
+			if isUnreserved(c) {
+				b.WriteByte(c)
+			} else {
+				b.WriteString(escaped[i : i+3])
+			}
+			i += 2
+			continue
+		}
+		b.WriteByte(escaped[i])
+	}
+	return b.String()
+}
+
+// uppercaseEscapes uppercases the hex digits of percent-escapes ("%c3%a9" -> "%C3%A9")
+func uppercaseEscapes(escaped string) string {
+	if !strings.Contains(escaped, "%") {
+		return escaped
+	}
+	b := []byte(escaped)
+	for i := 0; i+2 < len(b); i++ {
+		if b[i] == '%' && isHex(b[i+1]) && isHex(b[i+2]) {
+			b[i+1], b[i+2] = toUpperHex(b[i+1]), toUpperHex(b[i+2])
+			i += 2
+		}
+	}
+	return string(b)
+}
+
+// toUpperHex uppercases a hexadecimal letter
+func toUpperHex(c byte) byte {
+	if 'a' <= c && c <= 'f' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
+
+// isUnreserved reports whether c may appear in a URL without escaping
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// isHex reports whether c is a hexadecimal digit
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// unhex returns the value of a hexadecimal digit
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// splitList splits a comma-separated list, trimming spaces and dropping empty entries
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
\ No newline at end of file
@@ -1,12 +1,15 @@
 package main
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestNormalizeURL(t *testing.T) {
 	tests := []struct {
-		name          string
-		inputURL      string
-		expected      string
+		name     string
+		inputURL string
+		expected string
 	}{
 		{
 			name:     "remove scheme",
@@ -52,4 +55,122 @@ func TestNormalizeURL(t *testing.T) {
 			}
 		})
 	}
+}
+
+func TestNormalizePolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   normalizePolicy
+		inputURL string
+		expected string
+	}{
+		{
+			name:     "zero value matches normalizeURL",
+			policy:   normalizePolicy{},
+			inputURL: "https://Blog.Boot.dev/path/?page=2#top",
+			expected: "Blog.Boot.dev/path",
+		},
+		{
+			name:     "keep scheme",
+			policy:   normalizePolicy{KeepScheme: true},
+			inputURL: "http://blog.boot.dev/path",
+			expected: "http://blog.boot.dev/path",
+		},
+		{
+			name:     "keep all query params with sorted keys",
+			policy:   normalizePolicy{Query: queryKeepAll},
+			inputURL: "https://blog.boot.dev/list?sort=new&page=2",
+			expected: "blog.boot.dev/list?page=2&sort=new",
+		},
+		{
+			name:     "keep listed query params",
+			policy:   normalizePolicy{Query: queryKeepListed, KeepParams: []string{"page"}},
+			inputURL: "https://blog.boot.dev/list?sort=new&page=2&ref=home",
+			expected: "blog.boot.dev/list?page=2",
+		},
+		{
+			name:     "keep listed query params when none present",
+			policy:   normalizePolicy{Query: queryKeepListed, KeepParams: []string{"page"}},
+			inputURL: "https://blog.boot.dev/list?sort=new",
+			expected: "blog.boot.dev/list",
+		},
+		{
+			name:     "lowercase host",
+			policy:   normalizePolicy{LowercaseHost: true},
+			inputURL: "https://Blog.Boot.DEV/Path",
+			expected: "blog.boot.dev/Path",
+		},
+		{
+			name:     "remove default https port",
+			policy:   normalizePolicy{RemoveDefaultPort: true},
+			inputURL: "https://blog.boot.dev:443/path",
+			expected: "blog.boot.dev/path",
+		},
+		{
+			name:     "keep non-default port",
+			policy:   normalizePolicy{RemoveDefaultPort: true},
+			inputURL: "https://blog.boot.dev:8443/path",
+			expected: "blog.boot.dev:8443/path",
+		},
+		{
+			name:     "collapse dot segments",
+			policy:   normalizePolicy{CollapseDotSegments: true},
+			inputURL: "https://blog.boot.dev/a/./b/../c/",
+			expected: "blog.boot.dev/a/c",
+		},
+		{
+			name:     "strip index.html",
+			policy:   normalizePolicy{StripIndex: true},
+			inputURL: "https://blog.boot.dev/docs/index.html",
+			expected: "blog.boot.dev/docs",
+		},
+		{
+			name:     "strip root index.php",
+			policy:   normalizePolicy{StripIndex: true},
+			inputURL: "https://blog.boot.dev/index.php",
+			expected: "blog.boot.dev",
+		},
+		{
+			name:     "decode unreserved percent-escapes",
+			policy:   normalizePolicy{DecodeUnreserved: true},
+			inputURL: "https://blog.boot.dev/%7Euser/a%2fb/%41bc",
+			expected: "blog.boot.dev/~user/a%2Fb/Abc",
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := tc.policy.normalize(tc.inputURL)
+			if err != nil {
+				t.Errorf("Test %v - '%s' FAIL: unexpected error: %v", i, tc.name, err)
+				return
+			}
+			if actual != tc.expected {
+				t.Errorf("Test %v - %s FAIL: expected URL: %v, actual: %v", i, tc.name, tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestNewNormalizePolicy(t *testing.T) {
+	policy, err := newNormalizePolicy(true, "page, sort", "utm_*", "lowercase-host,index")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := normalizePolicy{
+		KeepScheme:    true,
+		Query:         queryKeepListed,
+		KeepParams:    []string{"page", "sort"},
+		LowercaseHost: true,
+		StripIndex:    true,
+		StripParams:   []string{"utm_*"},
+	}
+	if !reflect.DeepEqual(policy, expected) {
+		t.Errorf("expected %+v, got %+v", expected, policy)
+	}
+
+	if _, err := newNormalizePolicy(false, "none", "", "bogus"); err == nil {
+		t.Error("expected error for unknown option, got nil")
+	}
 }
\ No newline at end of file
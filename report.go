@@ -2,12 +2,20 @@ package main
 
 import (
 	"encoding/csv"
+	"fmt"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 )
 
 // writeCSVReport writes the crawled pages data to a CSV file
-func writeCSVReport(pages map[string]PageData, filename string) error {
+func writeCSVReport(pages pageStore, filename string) error {
+	return writeCSVReportWithFormat(pages, defaultReportFormat(), filename)
+}
+
+// writeCSVReportWithFormat writes the crawled pages data with the given columns and layout
+func writeCSVReportWithFormat(pages pageStore, format reportFormat, filename string) error {
 	file, err := os.Create(filename)
 	if err != nil {
 		return err
@@ -15,22 +23,330 @@ func writeCSVReport(pages map[string]PageData, filename string) error {
 	defer file.Close()
 
 	writer := csv.NewWriter(file)
+	writer.Comma = format.Delimiter
 	defer writer.Flush()
 
 	// Write header
-	header := []string{"page_url", "h1", "first_paragraph", "outgoing_link_urls", "image_urls"}
+	if header := format.header(); header != nil {
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+	}
+
+	// Write data rows
+	if err := pages.each(func(pageURL string, pageData PageData) error {
+		rows, err := format.rows(pageURL, pageData)
+		if err != nil {
+			return err
+		}
+		if err := writer.WriteAll(rows); err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// writeAssetReport writes one row per asset referenced by each crawled page
+func writeAssetReport(pages pageStore, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"page_url", "asset_url", "kind", "status_code", "content_type", "size_bytes", "error"}
 	if err := writer.Write(header); err != nil {
 		return err
 	}
 
-	// Write data rows
-	for pageURL, pageData := range pages {
+	if err := pages.each(func(pageURL string, pageData PageData) error {
+		for _, asset := range pageData.Assets {
+			row := []string{
+				displayURL(pageURL),
+				asset.URL,
+				string(asset.Kind),
+				formatOptionalInt(int64(asset.StatusCode)),
+				asset.ContentType,
+				formatOptionalInt(asset.Size),
+				asset.Error,
+			}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// writeAccessibilityReport writes one row per accessibility issue found on each crawled page
+func writeAccessibilityReport(pages pageStore, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"page_url", "issue", "element", "detail"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	if err := pages.each(func(pageURL string, pageData PageData) error {
+		for _, issue := range findAccessibilityIssues(pageData) {
+			row := []string{displayURL(pageURL), issue.Issue, issue.Element, issue.Detail}
+			if err := writer.Write(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// writeStructureReport writes heading counts and outline warnings for each crawled page
+func writeStructureReport(pages pageStore, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"page_url", "h1_count", "h2_count", "h3_count", "h4_count", "h5_count", "h6_count", "warnings", "outline"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	if err := pages.each(func(pageURL string, pageData PageData) error {
+		row := []string{displayURL(pageURL)}
+		for _, count := range pageData.Outline.Counts {
+			row = append(row, strconv.Itoa(count))
+		}
+		row = append(row, strings.Join(pageData.Outline.Warnings, "; "), formatOutline(pageData.Outline))
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// writeSchemaTypesReport writes how many pages declare each structured data type, across the whole site
+func writeSchemaTypesReport(pages pageStore, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"source", "type", "page_count"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	type schemaKey struct{ source, typ string }
+	counts := make(map[schemaKey]int)
+	if err := pages.each(func(_ string, pageData PageData) error {
+		seen := make(map[schemaKey]bool)
+		for _, item := range pageData.StructuredData.Items {
+			seen[schemaKey{item.Source, item.Type}] = true
+		}
+		if len(pageData.StructuredData.OpenGraph) > 0 {
+			seen[schemaKey{"opengraph", pageData.StructuredData.OpenGraph["og:type"]}] = true
+		}
+		if len(pageData.StructuredData.TwitterCard) > 0 {
+			seen[schemaKey{"twitter", pageData.StructuredData.TwitterCard["twitter:card"]}] = true
+		}
+		for key := range seen {
+			counts[key]++
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	keys := make([]schemaKey, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		if keys[i].source != keys[j].source {
+			return keys[i].source < keys[j].source
+		}
+		return keys[i].typ < keys[j].typ
+	})
+
+	for _, key := range keys {
+		if err := writer.Write([]string{key.source, key.typ, strconv.Itoa(counts[key])}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeSchemaIssuesReport writes invalid JSON-LD blocks and structured data missing required properties
+func writeSchemaIssuesReport(pages pageStore, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"page_url", "source", "type", "problem"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	if err := pages.each(func(pageURL string, pageData PageData) error {
+		data := pageData.StructuredData
+		displayed := displayURL(pageURL)
+		var rows [][]string
+		for _, msg := range data.JSONLDErrors {
+			rows = append(rows, []string{displayed, "json-ld", "", "invalid JSON: " + msg})
+		}
+		for _, item := range data.Items {
+			if missing := item.missingProperties(); len(missing) > 0 {
+				rows = append(rows, []string{displayed, item.Source, item.Type, "missing " + strings.Join(missing, ", ")})
+			}
+		}
+		if missing := data.missingOpenGraph(); len(missing) > 0 {
+			rows = append(rows, []string{displayed, "opengraph", data.OpenGraph["og:type"], "missing " + strings.Join(missing, ", ")})
+		}
+		if len(data.TwitterCard) > 0 && data.TwitterCard["twitter:card"] == "" {
+			rows = append(rows, []string{displayed, "twitter", "", "missing twitter:card"})
+		}
+		if err := writer.WriteAll(rows); err != nil {
+			return err
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// writeTrapReport writes the URLs that were not crawled because they looked like crawler traps
+func writeTrapReport(traps []suspectedTrap, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"url", "reason"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, trap := range traps {
+		if err := writer.Write([]string{trap.URL, trap.Reason}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeDuplicateReport writes one row per group of URLs that served identical main content
+func writeDuplicateReport(pages pageStore, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"content_hash", "url_count", "page_urls"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	groups, err := duplicateGroups(pages)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		urls := make([]string, len(group.URLs))
+		for i, pageURL := range group.URLs {
+			urls[i] = displayURL(pageURL)
+		}
+		row := []string{group.ContentHash, strconv.Itoa(len(group.URLs)), strings.Join(urls, ";")}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeNearDuplicateReport writes one row per cluster of pages whose text is at least threshold similar
+func writeNearDuplicateReport(pages pageStore, threshold float64, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	header := []string{"cluster", "url_count", "min_similarity", "page_urls"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	clusters, err := nearDuplicateClusters(pages, threshold)
+	if err != nil {
+		return err
+	}
+	for i, cluster := range clusters {
+		urls := make([]string, len(cluster.URLs))
+		for j, pageURL := range cluster.URLs {
+			urls[j] = displayURL(pageURL)
+		}
 		row := []string{
-			pageURL,
-			pageData.H1,
-			pageData.FirstParagraph,
-			strings.Join(pageData.OutgoingLinks, ";"),
-			strings.Join(pageData.ImageURLs, ";"),
+			strconv.Itoa(i + 1),
+			strconv.Itoa(len(cluster.URLs)),
+			strconv.FormatFloat(cluster.MinSimilarity, 'f', 2, 64),
+			strings.Join(urls, ";"),
 		}
 		if err := writer.Write(row); err != nil {
 			return err
@@ -39,3 +355,25 @@ func writeCSVReport(pages map[string]PageData, filename string) error {
 
 	return nil
 }
+
+// formatOutline renders an outline as indented "hN text" lines
+func formatOutline(outline HeadingOutline) string {
+	var b strings.Builder
+	var walk func([]Heading, int)
+	walk = func(headings []Heading, depth int) {
+		for _, h := range headings {
+			fmt.Fprintf(&b, "%sh%d %s\n", strings.Repeat("  ", depth), h.Level, h.Text)
+			walk(h.Children, depth+1)
+		}
+	}
+	walk(outline.Headings, 0)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// formatOptionalInt formats n, leaving the cell empty when it is zero (unknown)
+func formatOptionalInt(n int64) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.FormatInt(n, 10)
+}
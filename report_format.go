@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// reportColumn is one column of the main CSV report. Columns either hold a single value
+// or a list, which is joined with the list separator or, in long format, spread over rows.
+type reportColumn struct {
+	name  string
+	value func(pageURL string, pageData PageData) (string, error)
+	list  func(pageData PageData) []string
+}
+
+// reportColumns lists every available column in the default order
+var reportColumns = []reportColumn{
+	{name: "page_url", value: func(pageURL string, _ PageData) (string, error) { return displayURL(pageURL), nil }},
+	{name: "h1", value: textColumn(func(p PageData) string { return p.H1 })},
+	{name: "first_paragraph", value: textColumn(func(p PageData) string { return p.FirstParagraph })},
+	{name: "outgoing_link_urls", list: func(p PageData) []string { return p.OutgoingLinks }},
+	{name: "image_urls", list: func(p PageData) []string { return p.ImageURLs }},
+	{name: "emails", list: func(p PageData) []string { return p.Emails }},
+	{name: "phones", list: func(p PageData) []string { return p.Phones }},
+	{name: "script_links", list: func(p PageData) []string { return p.ScriptLinks }},
+	{name: "other_links", list: func(p PageData) []string { return p.OtherLinks }},
+	{name: "heading_outline", value: func(_ string, p PageData) (string, error) {
+		outline, err := json.Marshal(p.Outline)
+		return string(outline), err
+	}},
+	{name: "word_count", value: textColumn(func(p PageData) string { return strconv.Itoa(p.WordCount) })},
+	{name: "reading_minutes", value: textColumn(func(p PageData) string { return strconv.Itoa(p.ReadingMinutes) })},
+	{name: "charset", value: textColumn(func(p PageData) string { return p.Charset })},
+	{name: "declared_content_type", value: textColumn(func(p PageData) string { return p.DeclaredContentType })},
+	{name: "sniffed_content_type", value: textColumn(func(p PageData) string { return p.SniffedContentType })},
+	{name: "content_hash", value: textColumn(func(p PageData) string { return p.ContentHash })},
+	{name: "duplicate_of", value: textColumn(func(p PageData) string { return displayURL(p.DuplicateOf) })},
+	{name: "title", value: textColumn(func(p PageData) string { return p.Title })},
+	{name: "status_code", value: textColumn(func(p PageData) string { return formatOptionalInt(int64(p.StatusCode)) })},
+	{name: "fetch_error", value: textColumn(func(p PageData) string { return p.FetchError })},
+}
+
+// textColumn adapts a field getter that can't fail to a column value
+func textColumn(get func(PageData) string) func(string, PageData) (string, error) {
+	return func(_ string, pageData PageData) (string, error) {
+		return get(pageData), nil
+	}
+}
+
+// reportFormat controls the columns and layout of the main CSV report
+type reportFormat struct {
+	Columns       []reportColumn
+	ListSeparator string // joins list values in one cell
+	Long          bool   // one row per list value instead of joining them
+	Delimiter     rune   // between cells: ',' for CSV or '\t' for TSV
+	NoHeader      bool
+}
+
+// defaultReportFormat returns every column, comma-delimited, with lists joined by ";"
+func defaultReportFormat() reportFormat {
+	return reportFormat{
+		Columns:       reportColumns,
+		ListSeparator: ";",
+		Delimiter:     ',',
+	}
+}
+
+// parseReportColumns looks up a comma-separated list of column names, in the order given.
+// An empty list selects every column.
+func parseReportColumns(names string) ([]reportColumn, error) {
+	if strings.TrimSpace(names) == "" {
+		return reportColumns, nil
+	}
+
+	byName := make(map[string]reportColumn, len(reportColumns))
+	available := make([]string, len(reportColumns))
+	for i, column := range reportColumns {
+		byName[column.name] = column
+		available[i] = column.name
+	}
+
+	var columns []reportColumn
+	for _, name := range splitList(names) {
+		column, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown report column %q (available: %s)", name, strings.Join(available, ", "))
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// header returns the column names, or nil if the header is turned off
+func (f reportFormat) header() []string {
+	if f.NoHeader {
+		return nil
+	}
+	names := make([]string, len(f.Columns))
+	for i, column := range f.Columns {
+		names[i] = column.name
+	}
+	return names
+}
+
+// rows formats one page. It is a single row unless the format is long, in which case each
+// value of each list column gets its own row, with the other list columns left empty.
+func (f reportFormat) rows(pageURL string, pageData PageData) ([][]string, error) {
+	base := make([]string, len(f.Columns))
+	var listColumns []int
+	for i, column := range f.Columns {
+		if column.list == nil {
+			value, err := column.value(pageURL, pageData)
+			if err != nil {
+				return nil, err
+			}
+			base[i] = value
+			continue
+		}
+		if f.Long {
+			listColumns = append(listColumns, i)
+		} else {
+			base[i] = f.joinList(column.list(pageData))
+		}
+	}
+
+	var rows [][]string
+	for _, i := range listColumns {
+		for _, item := range f.Columns[i].list(pageData) {
+			row := append([]string(nil), base...)
+			row[i] = item
+			rows = append(rows, row)
+		}
+	}
+	if len(rows) == 0 {
+		rows = append(rows, base)
+	}
+	return rows, nil
+}
+
+// joinList joins list values with the separator. Occurrences of the separator inside a
+// value are percent-encoded so the cell splits on the separator into one part per value,
+// and so is "%" itself, so percent-decoding each part gives back the value exactly.
+func (f reportFormat) joinList(items []string) string {
+	escaper := strings.NewReplacer("%", "%25", f.ListSeparator, percentEncodeAll(f.ListSeparator))
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i] = escaper.Replace(item)
+	}
+	return strings.Join(parts, f.ListSeparator)
+}
+
+// percentEncodeAll percent-encodes every byte of s
+func percentEncodeAll(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		fmt.Fprintf(&b, "%%%02X", s[i])
+	}
+	return b.String()
+}
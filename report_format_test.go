@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/csv"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseReportColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty selects all", input: "", want: defaultReportFormat().header()},
+		{name: "order is kept", input: "title,page_url", want: []string{"title", "page_url"}},
+		{name: "spaces are trimmed", input: " status_code , fetch_error ", want: []string{"status_code", "fetch_error"}},
+		{name: "unknown column", input: "page_url,nope", wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			columns, err := parseReportColumns(tc.input)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := reportFormat{Columns: columns}.header()
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("columns = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestReportFormatRows(t *testing.T) {
+	page := PageData{
+		URL:           "https://example.com/",
+		Title:         "Home",
+		OutgoingLinks: []string{"https://example.com/a;b", "https://example.com/c"},
+		ImageURLs:     []string{"https://example.com/logo.png"},
+	}
+	columns, err := parseReportColumns("page_url,title,outgoing_link_urls,image_urls")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		format reportFormat
+		want   [][]string
+	}{
+		{
+			name:   "separator inside a value is escaped",
+			format: reportFormat{Columns: columns, ListSeparator: ";"},
+			want: [][]string{
+				{"example.com", "Home", "https://example.com/a%3Bb;https://example.com/c", "https://example.com/logo.png"},
+			},
+		},
+		{
+			name:   "custom separator",
+			format: reportFormat{Columns: columns, ListSeparator: " | "},
+			want: [][]string{
+				{"example.com", "Home", "https://example.com/a;b | https://example.com/c", "https://example.com/logo.png"},
+			},
+		},
+		{
+			name:   "long format",
+			format: reportFormat{Columns: columns, ListSeparator: ";", Long: true},
+			want: [][]string{
+				{"example.com", "Home", "https://example.com/a;b", ""},
+				{"example.com", "Home", "https://example.com/c", ""},
+				{"example.com", "Home", "", "https://example.com/logo.png"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.format.rows("example.com", page)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("rows =\n%q\nwant\n%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestJoinList(t *testing.T) {
+	format := reportFormat{ListSeparator: ";"}
+	tests := []struct {
+		items []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{"https://example.com/a", "https://example.com/b"}, "https://example.com/a;https://example.com/b"},
+		{[]string{"https://example.com/a;b"}, "https://example.com/a%3Bb"},
+		// "%" is escaped too, so an escape already in a value isn't mistaken for a separator
+		{[]string{"https://example.com/a%20b", "https://example.com/a%3Bb"}, "https://example.com/a%2520b;https://example.com/a%253Bb"},
+	}
+	for _, tc := range tests {
+		if got := format.joinList(tc.items); got != tc.want {
+			t.Errorf("joinList(%q) = %q, want %q", tc.items, got, tc.want)
+		}
+	}
+}
+
+func TestJoinListRoundTrip(t *testing.T) {
+	items := []string{
+		"https://example.com/a;b",
+		"https://example.com/a%3Bb",
+		"https://example.com/a%20b?q=1%",
+		"https://example.com/a | b",
+		"",
+	}
+	for _, separator := range []string{";", " | ", ","} {
+		format := reportFormat{ListSeparator: separator}
+		parts := strings.Split(format.joinList(items), separator)
+		var got []string
+		for _, part := range parts {
+			item, err := url.PathUnescape(part)
+			if err != nil {
+				t.Fatalf("separator %q: unexpected error: %v", separator, err)
+			}
+			got = append(got, item)
+		}
+		if !reflect.DeepEqual(got, items) {
+			t.Errorf("separator %q: expected %q, got %q", separator, items, got)
+		}
+	}
+}
+
+func TestReportFormatLongWithoutLists(t *testing.T) {
+	columns, err := parseReportColumns("page_url,outgoing_link_urls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	format := reportFormat{Columns: columns, ListSeparator: ";", Long: true}
+	got, err := format.rows("example.com/empty", PageData{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"example.com/empty", ""}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %q, want %q", got, want)
+	}
+}
+
+func TestWriteCSVReportWithFormatTSV(t *testing.T) {
+	columns, err := parseReportColumns("page_url,title")
+	if err != nil {
+		t.Fatal(err)
+	}
+	format := reportFormat{Columns: columns, ListSeparator: ";", Delimiter: '\t', NoHeader: true}
+	pages := map[string]PageData{
+		"example.com/a": {URL: "https://example.com/a", Title: "Tabs\tand \"quotes\""},
+	}
+
+	filename := filepath.Join(t.TempDir(), "report.tsv")
+	if err := writeCSVReportWithFormat(newMemoryStore(pages), format, filename); err != nil {
+		t.Fatalf("writeCSVReportWithFormat: %v", err)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.Comma = '\t'
+	records, err := reader.ReadAll()
+	if err != nil {
+		t.Fatalf("reading TSV: %v", err)
+	}
+	want := [][]string{{"example.com/a", "Tabs\tand \"quotes\""}}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records = %q, want %q", records, want)
+	}
+
+	data, _ := os.ReadFile(filename)
+	if strings.Contains(string(data), "page_url") {
+		t.Errorf("header written despite NoHeader: %q", data)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// streamBuffer is how many finished pages may wait for the stream writer before crawlPage blocks
+const streamBuffer = 64
+
+// pageRecord is one finished page on its way to a stream
+type pageRecord struct {
+	URL  string
+	Page PageData
+}
+
+// pageStream writes pages to a report file as the crawl finishes them, so long crawls can be
+// tailed and a crash keeps everything flushed so far. Files ending in .jsonl or .ndjson get
+// one JSON object per line; anything else gets the rows of the CSV report in the given format.
+type pageStream struct {
+	records chan pageRecord
+	done    chan error
+}
+
+// pageRowWriter writes one page in a stream's format
+type pageRowWriter interface {
+	writeHeader() error
+	writePage(pageURL string, pageData PageData) error
+	flush() error
+}
+
+// openPageStream starts a stream into filename, flushing at least every flushInterval
+// (0 flushes after every page). With appendMode an existing file is continued, as when
+// resuming a crawl; pages crawled after the last checkpoint may then appear twice.
+func openPageStream(filename string, format reportFormat, appendMode bool, flushInterval time.Duration) (*pageStream, error) {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendMode {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(filename, flags, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	var rows pageRowWriter
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".jsonl", ".ndjson":
+		rows = &jsonLinesWriter{writer: bufio.NewWriter(file)}
+	default:
+		writer := csv.NewWriter(file)
+		writer.Comma = format.Delimiter
+		rows = &csvRowWriter{writer: writer, format: format}
+	}
+	if info.Size() == 0 {
+		if err := rows.writeHeader(); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	stream := &pageStream{
+		records: make(chan pageRecord, streamBuffer),
+		done:    make(chan error, 1),
+	}
+	go stream.run(file, rows, flushInterval)
+	return stream, nil
+}
+
+// run writes records until the stream is closed. After a write error it keeps draining
+// records so the crawl isn't blocked, and reports the first error on close.
+func (s *pageStream) run(file *os.File, rows pageRowWriter, flushInterval time.Duration) {
+	var ticks <-chan time.Time
+	if flushInterval > 0 {
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	var firstErr error
+	keep := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	for {
+		select {
+		case record, ok := <-s.records:
+			if !ok {
+				if firstErr == nil {
+					keep(rows.flush())
+				}
+				keep(file.Close())
+				s.done <- firstErr
+				return
+			}
+			if firstErr != nil {
+				continue
+			}
+			keep(rows.writePage(record.URL, record.Page))
+			if ticks == nil && firstErr == nil {
+				keep(rows.flush())
+			}
+		case <-ticks:
+			if firstErr == nil {
+				keep(rows.flush())
+			}
+		}
+	}
+}
+
+// send queues a finished page for writing
+func (s *pageStream) send(pageURL string, pageData PageData) {
+	s.records <- pageRecord{URL: pageURL, Page: pageData}
+}
+
+// close writes the remaining pages, closes the file and returns the first write error.
+// No pages may be sent after close.
+func (s *pageStream) close() error {
+	close(s.records)
+	return <-s.done
+}
+
+// csvRowWriter streams rows of the main CSV report
+type csvRowWriter struct {
+	writer *csv.Writer
+	format reportFormat
+}
+
+func (w *csvRowWriter) writeHeader() error {
+	if header := w.format.header(); header != nil {
+		return w.writer.Write(header)
+	}
+	return nil
+}
+
+func (w *csvRowWriter) writePage(pageURL string, pageData PageData) error {
+	rows, err := w.format.rows(pageURL, pageData)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := w.writer.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *csvRowWriter) flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// jsonLinesWriter streams the entries of the JSON report, one per line
+type jsonLinesWriter struct {
+	writer *bufio.Writer
+}
+
+func (w *jsonLinesWriter) writeHeader() error {
+	return nil
+}
+
+func (w *jsonLinesWriter) writePage(pageURL string, pageData PageData) error {
+	data, err := json.Marshal(jsonPage{NormalizedURL: pageURL, Page: pageData})
+	if err != nil {
+		return err
+	}
+	if _, err := w.writer.Write(data); err != nil {
+		return err
+	}
+	return w.writer.WriteByte('\n')
+}
+
+func (w *jsonLinesWriter) flush() error {
+	return w.writer.Flush()
+}
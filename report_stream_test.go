@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPageStreamFlushesBeforeClose(t *testing.T) {
+	tests := []struct {
+		name          string
+		file          string
+		flushInterval time.Duration
+	}{
+		{name: "csv after every page", file: "pages.csv", flushInterval: 0},
+		{name: "csv on interval", file: "pages.csv", flushInterval: 10 * time.Millisecond},
+		{name: "json lines after every page", file: "pages.jsonl", flushInterval: 0},
+		{name: "ndjson on interval", file: "pages.ndjson", flushInterval: 10 * time.Millisecond},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), tc.file)
+			stream, err := openPageStream(filename, defaultReportFormat(), false, tc.flushInterval)
+			if err != nil {
+				t.Fatalf("openPageStream: %v", err)
+			}
+			stream.send("example.com/a", PageData{URL: "https://example.com/a", H1: "A"})
+
+			// The page must reach the file while the stream is still open
+			deadline := time.Now().Add(2 * time.Second)
+			for {
+				data, _ := os.ReadFile(filename)
+				if strings.Contains(string(data), "example.com/a") {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("page not flushed before close; file has %q", data)
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			if err := stream.close(); err != nil {
+				t.Fatalf("close: %v", err)
+			}
+		})
+	}
+}
+
+func TestPageStreamFormats(t *testing.T) {
+	dir := t.TempDir()
+	pages := []pageRecord{
+		{URL: "example.com/a", Page: PageData{URL: "https://example.com/a", H1: "A", StatusCode: 200}},
+		{URL: "example.com/b", Page: PageData{URL: "https://example.com/b", FetchError: "timeout"}},
+	}
+
+	csvFile := filepath.Join(dir, "pages.csv")
+	jsonFile := filepath.Join(dir, "pages.jsonl")
+	for _, filename := range []string{csvFile, jsonFile} {
+		stream, err := openPageStream(filename, defaultReportFormat(), false, time.Second)
+		if err != nil {
+			t.Fatalf("openPageStream: %v", err)
+		}
+		for _, record := range pages {
+			stream.send(record.URL, record.Page)
+		}
+		if err := stream.close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+	}
+
+	file, err := os.Open(csvFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("reading CSV: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("expected header and 2 rows, got %d records", len(records))
+	}
+	if strings.Join(records[0], ",") != strings.Join(defaultReportFormat().header(), ",") {
+		t.Errorf("header = %v, want the CSV report header", records[0])
+	}
+	if records[1][0] != "example.com/a" || records[1][1] != "A" {
+		t.Errorf("first row = %v", records[1])
+	}
+
+	jsonData, err := os.Open(jsonFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer jsonData.Close()
+	var got []jsonPage
+	scanner := bufio.NewScanner(jsonData)
+	for scanner.Scan() {
+		var entry jsonPage
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("line %q is not JSON: %v", scanner.Text(), err)
+		}
+		got = append(got, entry)
+	}
+	if len(got) != 2 || got[1].NormalizedURL != "example.com/b" || got[1].Page.FetchError != "timeout" {
+		t.Errorf("unexpected JSON lines: %+v", got)
+	}
+}
+
+func TestPageStreamAppend(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "pages.csv")
+	for _, pageURL := range []string{"example.com/a", "example.com/b"} {
+		stream, err := openPageStream(filename, defaultReportFormat(), true, 0)
+		if err != nil {
+			t.Fatalf("openPageStream: %v", err)
+		}
+		stream.send(pageURL, PageData{URL: "https://" + pageURL})
+		if err := stream.close(); err != nil {
+			t.Fatalf("close: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected one header and 2 rows, got %q", lines)
+	}
+	if !strings.HasPrefix(lines[1], "example.com/a") || !strings.HasPrefix(lines[2], "example.com/b") {
+		t.Errorf("rows not appended in order: %q", lines)
+	}
+}
+
+func TestCrawlStreamsEveryPage(t *testing.T) {
+	server := createTestServer(0)
+	defer server.Close()
+	baseURL, _ := url.Parse(server.URL)
+
+	filename := filepath.Join(t.TempDir(), "pages.jsonl")
+	stream, err := openPageStream(filename, defaultReportFormat(), false, time.Second)
+	if err != nil {
+		t.Fatalf("openPageStream: %v", err)
+	}
+	cfg := &config{
+		pages:              newMemoryStore(nil),
+		baseURL:            baseURL,
+		mu:                 &sync.Mutex{},
+		concurrencyControl: make(chan struct{}, 3),
+		wg:                 &sync.WaitGroup{},
+		stream:             stream,
+	}
+	cfg.enqueue(server.URL, 0)
+	if err := cfg.crawl(); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != cfg.pages.count() {
+		t.Errorf("streamed %d pages, crawled %d", len(lines), cfg.pages.count())
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/csv"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -21,7 +22,7 @@ func TestWriteCSVReportBasic(t *testing.T) {
 	tmpDir := t.TempDir()
 	filename := filepath.Join(tmpDir, "test_report.csv")
 
-	err := writeCSVReport(pages, filename)
+	err := writeCSVReport(newMemoryStore(pages), filename)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -94,7 +95,7 @@ func TestWriteCSVReportMultiplePages(t *testing.T) {
 	tmpDir := t.TempDir()
 	filename := filepath.Join(tmpDir, "test_report.csv")
 
-	err := writeCSVReport(pages, filename)
+	err := writeCSVReport(newMemoryStore(pages), filename)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -123,7 +124,7 @@ func TestWriteCSVReportEmptyPages(t *testing.T) {
 	tmpDir := t.TempDir()
 	filename := filepath.Join(tmpDir, "test_report.csv")
 
-	err := writeCSVReport(pages, filename)
+	err := writeCSVReport(newMemoryStore(pages), filename)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -160,7 +161,7 @@ func TestWriteCSVReportEmptySlices(t *testing.T) {
 	tmpDir := t.TempDir()
 	filename := filepath.Join(tmpDir, "test_report.csv")
 
-	err := writeCSVReport(pages, filename)
+	err := writeCSVReport(newMemoryStore(pages), filename)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -191,8 +192,267 @@ func TestWriteCSVReportInvalidPath(t *testing.T) {
 	pages := map[string]PageData{}
 
 	// Try to write to an invalid path
-	err := writeCSVReport(pages, "/nonexistent/directory/report.csv")
+	err := writeCSVReport(newMemoryStore(pages), "/nonexistent/directory/report.csv")
 	if err == nil {
 		t.Error("expected error for invalid path, got nil")
 	}
 }
+
+func TestWriteCSVReportContactColumns(t *testing.T) {
+	pages := map[string]PageData{
+		"example.com/contact": {
+			URL:         "https://example.com/contact",
+			Emails:      []string{"hello@example.com", "sales@example.com"},
+			Phones:      []string{"+1-555-0100"},
+			ScriptLinks: []string{"javascript:void(0)"},
+		},
+	}
+
+	tmpDir := t.TempDir()
+	filename := filepath.Join(tmpDir, "test_report.csv")
+
+	if err := writeCSVReport(newMemoryStore(pages), filename); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("failed to open CSV: %v", err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read CSV: %v", err)
+	}
+
+	if records[0][5] != "emails" || records[0][6] != "phones" || records[0][7] != "script_links" {
+		t.Errorf("unexpected contact header columns: %v", records[0][5:])
+	}
+	if records[1][5] != "hello@example.com;sales@example.com" {
+		t.Errorf("expected emails joined with ';', got %q", records[1][5])
+	}
+	if records[1][6] != "+1-555-0100" {
+		t.Errorf("expected phones '+1-555-0100', got %q", records[1][6])
+	}
+	if records[1][7] != "javascript:void(0)" {
+		t.Errorf("expected script_links 'javascript:void(0)', got %q", records[1][7])
+	}
+}
+
+func TestWriteStructureReport(t *testing.T) {
+	pages := map[string]PageData{
+		"example.com": {
+			URL:     "https://example.com",
+			Outline: getHeadingOutlineFromHTML("<h1>Home</h1><h3>News</h3>"),
+		},
+	}
+
+	tmpDir := t.TempDir()
+	filename := filepath.Join(tmpDir, "structure.csv")
+
+	if err := writeStructureReport(newMemoryStore(pages), filename); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("failed to open CSV: %v", err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read CSV: %v", err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected 2 rows (header + 1 data), got %d", len(records))
+	}
+	row := records[1]
+	if row[1] != "1" || row[3] != "1" {
+		t.Errorf("expected one h1 and one h3, got h1=%q h3=%q", row[1], row[3])
+	}
+	if row[7] != `skipped level: h3 after h1 ("News")` {
+		t.Errorf("unexpected warnings: %q", row[7])
+	}
+	if row[8] != "h1 Home\n  h3 News" {
+		t.Errorf("unexpected outline: %q", row[8])
+	}
+}
+
+func TestWriteSchemaTypesReport(t *testing.T) {
+	pages := map[string]PageData{
+		"example.com": {StructuredData: StructuredData{Items: []SchemaItem{
+			{Source: "json-ld", Type: "WebSite"},
+			{Source: "json-ld", Type: "Organization"},
+		}}},
+		"example.com/post": {StructuredData: StructuredData{Items: []SchemaItem{
+			{Source: "json-ld", Type: "Organization"},
+			{Source: "json-ld", Type: "Organization"},
+		}}},
+	}
+
+	tmpDir := t.TempDir()
+	filename := filepath.Join(tmpDir, "schema_types.csv")
+
+	if err := writeSchemaTypesReport(newMemoryStore(pages), filename); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("failed to open CSV: %v", err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read CSV: %v", err)
+	}
+
+	expected := [][]string{
+		{"source", "type", "page_count"},
+		{"json-ld", "Organization", "2"},
+		{"json-ld", "WebSite", "1"},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("expected %v, got %v", expected, records)
+	}
+}
+
+func TestWriteSchemaIssuesReport(t *testing.T) {
+	pages := map[string]PageData{
+		"example.com/post": {StructuredData: StructuredData{
+			Items:        []SchemaItem{{Source: "json-ld", Type: "Article", Properties: []string{"headline", "author"}}},
+			JSONLDErrors: []string{"block 2: unexpected end of JSON input"},
+		}},
+	}
+
+	tmpDir := t.TempDir()
+	filename := filepath.Join(tmpDir, "schema_issues.csv")
+
+	if err := writeSchemaIssuesReport(newMemoryStore(pages), filename); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("failed to open CSV: %v", err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read CSV: %v", err)
+	}
+
+	expected := [][]string{
+		{"page_url", "source", "type", "problem"},
+		{"example.com/post", "json-ld", "", "invalid JSON: block 2: unexpected end of JSON input"},
+		{"example.com/post", "json-ld", "Article", "missing datePublished"},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("expected %v, got %v", expected, records)
+	}
+}
+
+func TestWriteTrapReport(t *testing.T) {
+	traps := []suspectedTrap{
+		{URL: "https://example.com/a/b/a/b/a/b", Reason: `path segment "a" repeated 3 times`},
+	}
+
+	tmpDir := t.TempDir()
+	filename := filepath.Join(tmpDir, "traps.csv")
+
+	if err := writeTrapReport(traps, filename); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("failed to open CSV: %v", err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read CSV: %v", err)
+	}
+
+	expected := [][]string{
+		{"url", "reason"},
+		{"https://example.com/a/b/a/b/a/b", `path segment "a" repeated 3 times`},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("expected %v, got %v", expected, records)
+	}
+}
+
+func TestWriteDuplicateReport(t *testing.T) {
+	pages := map[string]PageData{
+		"example.com/a":      {ContentHash: "abc123"},
+		"example.com/b":      {ContentHash: "abc123", DuplicateOf: "example.com/a"},
+		"example.com/unique": {ContentHash: "def456"},
+	}
+
+	tmpDir := t.TempDir()
+	filename := filepath.Join(tmpDir, "duplicates.csv")
+
+	if err := writeDuplicateReport(newMemoryStore(pages), filename); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("failed to open CSV: %v", err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read CSV: %v", err)
+	}
+
+	expected := [][]string{
+		{"content_hash", "url_count", "page_urls"},
+		{"abc123", "2", "example.com/a;example.com/b"},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("expected %v, got %v", expected, records)
+	}
+}
+
+func TestWriteNearDuplicateReport(t *testing.T) {
+	pages := map[string]PageData{
+		"example.com/2024/01/05": {SimHash: 0xF0F0F0F0F0F0F0F0},
+		"example.com/2024/01/06": {SimHash: 0xF0F0F0F0F0F0F0F1},
+		"example.com/about":      {SimHash: 0x0F0F0F0F0F0F0F0F},
+	}
+
+	tmpDir := t.TempDir()
+	filename := filepath.Join(tmpDir, "near_duplicates.csv")
+
+	if err := writeNearDuplicateReport(newMemoryStore(pages), 0.9, filename); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("failed to open CSV: %v", err)
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read CSV: %v", err)
+	}
+
+	expected := [][]string{
+		{"cluster", "url_count", "min_similarity", "page_urls"},
+		{"1", "2", "0.98", "example.com/2024/01/05;example.com/2024/01/06"},
+	}
+	if !reflect.DeepEqual(records, expected) {
+		t.Errorf("expected %v, got %v", expected, records)
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// reportHandler serves the files in dir, sending "/" to the HTML report when there is one
+func reportHandler(dir string) http.Handler {
+	files := http.FileServer(http.Dir(dir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" {
+			if _, err := os.Stat(filepath.Join(dir, "report.html")); err == nil {
+				http.Redirect(w, r, "/report.html", http.StatusFound)
+				return
+			}
+		}
+		files.ServeHTTP(w, r)
+	})
+}
+
+// runServe implements "crawler serve [flags] [dir]" and returns the exit code
+func runServe(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", "localhost:8080", "address to listen on")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: crawler serve [flags] [dir]")
+		fmt.Fprintln(flags.Output(), "Serves the reports in dir (default: the current directory) until interrupted.")
+		flags.PrintDefaults()
+	}
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return parseExitCode(err)
+	}
+	if len(positional) > 1 {
+		flags.Usage()
+		return exitUsage
+	}
+	dir := "."
+	if len(positional) == 1 {
+		dir = positional[0]
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		fmt.Fprintf(os.Stderr, "%s is not a directory\n", dir)
+		return exitUsage
+	}
+
+	fmt.Printf("Serving %s at http://%s/\n", dir, *addr)
+	if err := http.ListenAndServe(*addr, reportHandler(dir)); err != nil {
+		fmt.Fprintf(os.Stderr, "error serving reports: %v\n", err)
+		return exitCrawlError
+	}
+	return exitOK
+}
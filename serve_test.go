@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReportHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        []string
+		path         string
+		wantStatus   int
+		wantLocation string
+	}{
+		{name: "root redirects to the HTML report", files: []string{"report.html"}, path: "/", wantStatus: http.StatusFound, wantLocation: "/report.html"},
+		{name: "root lists files without a report", files: []string{"report.csv"}, path: "/", wantStatus: http.StatusOK},
+		{name: "files are served", files: []string{"report.csv"}, path: "/report.csv", wantStatus: http.StatusOK},
+		{name: "missing files", path: "/nope.csv", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range tc.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte("data"), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			recorder := httptest.NewRecorder()
+			reportHandler(dir).ServeHTTP(recorder, httptest.NewRequest("GET", tc.path, nil))
+			if recorder.Code != tc.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tc.wantStatus)
+			}
+			if location := recorder.Header().Get("Location"); location != tc.wantLocation {
+				t.Errorf("Location = %q, want %q", location, tc.wantLocation)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"hash/fnv"
+	"math/bits"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// shingleSize is the number of consecutive words hashed together when fingerprinting text
+const shingleSize = 3
+
+// nearDuplicateCluster is a set of pages whose text is at least the threshold similar to another page in the set
+type nearDuplicateCluster struct {
+	URLs          []string // sorted
+	MinSimilarity float64  // lowest similarity between pages that joined the cluster
+}
+
+// simhash computes a 64-bit SimHash of text's word shingles. Texts that share most of their
+// shingles produce hashes that differ in few bits. Text too short to shingle hashes to 0.
+func simhash(text string) uint64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) < shingleSize {
+		return 0
+	}
+
+	var weights [64]int
+	for i := 0; i+shingleSize <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:i+shingleSize], " ")))
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var fingerprint uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			fingerprint |= 1 << bit
+		}
+	}
+	return fingerprint
+}
+
+// simhashSimilarity returns the fraction of bits two SimHashes share, from 0 to 1
+func simhashSimilarity(a, b uint64) float64 {
+	return 1 - float64(bits.OnesCount64(a^b))/64
+}
+
+// nearDuplicateClusters groups pages whose SimHash similarity is at least threshold,
+// joining clusters transitively. Pages without a SimHash are ignored. Clusters are
+// sorted by size, largest first, then by their first URL.
+//
+// Rather than comparing every pair of pages, hashes are split into bands: two hashes
+// differing in at most k bits agree exactly on at least one of k+1 bands, so only
+// pages sharing a band value are compared.
+func nearDuplicateClusters(pages pageStore, threshold float64) ([]nearDuplicateCluster, error) {
+	// Only the hashes are kept in memory, not the pages
+	var urls []string
+	var hashes []uint64
+	err := pages.each(func(pageURL string, pageData PageData) error {
+		if pageData.SimHash != 0 {
+			urls = append(urls, pageURL)
+			hashes = append(hashes, pageData.SimHash)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Union-find over page indexes
+	parent := make([]int, len(urls))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	minSimilarity := make(map[int]float64)
+	union := func(i, j int, similarity float64) {
+		rootI, rootJ := find(i), find(j)
+		lowest := similarity
+		for _, root := range []int{rootI, rootJ} {
+			if s, ok := minSimilarity[root]; ok && s < lowest {
+				lowest = s
+			}
+		}
+		if rootI != rootJ {
+			parent[rootJ] = rootI
+			delete(minSimilarity, rootJ)
+		}
+		minSimilarity[rootI] = lowest
+	}
+
+	// Pages with identical hashes join at once, so only one of them is compared further
+	var unique []int
+	firstWithHash := make(map[uint64]int)
+	for i, hash := range hashes {
+		if first, ok := firstWithHash[hash]; ok {
+			union(first, i, 1)
+			continue
+		}
+		firstWithHash[hash] = i
+		unique = append(unique, i)
+	}
+
+	for _, bucket := range simhashBuckets(hashes, unique, maxSimhashDistance(threshold)) {
+		for a := 0; a < len(bucket); a++ {
+			for b := a + 1; b < len(bucket); b++ {
+				i, j := bucket[a], bucket[b]
+				if similarity := simhashSimilarity(hashes[i], hashes[j]); similarity >= threshold {
+					union(i, j, similarity)
+				}
+			}
+		}
+	}
+
+	members := make(map[int][]string)
+	for i, pageURL := range urls {
+		root := find(i)
+		members[root] = append(members[root], pageURL)
+	}
+
+	var clusters []nearDuplicateCluster
+	for root, clusterURLs := range members {
+		if len(clusterURLs) < 2 {
+			continue
+		}
+		clusters = append(clusters, nearDuplicateCluster{URLs: clusterURLs, MinSimilarity: minSimilarity[root]})
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].URLs) != len(clusters[j].URLs) {
+			return len(clusters[i].URLs) > len(clusters[j].URLs)
+		}
+		return clusters[i].URLs[0] < clusters[j].URLs[0]
+	})
+	return clusters, nil
+}
+
+// maxSimhashDistance returns the most bits two SimHashes may differ in and still be at least threshold similar
+func maxSimhashDistance(threshold float64) int {
+	// The small tolerance keeps float error from losing a distance exactly at the threshold
+	distance := int((1-threshold)*64 + 1e-9)
+	return min(max(distance, 0), 63)
+}
+
+// simhashBuckets splits each indexed hash into distance+1 bands and groups the indexes
+// by band position and value, keeping only groups with more than one member
+func simhashBuckets(hashes []uint64, indexes []int, distance int) [][]int {
+	bands := distance + 1
+	buckets := make(map[[2]uint64][]int)
+	for band := 0; band < bands; band++ {
+		low, high := band*64/bands, (band+1)*64/bands
+		mask := uint64(1)<<(high-low) - 1
+		for _, i := range indexes {
+			key := [2]uint64{uint64(band), hashes[i] >> low & mask}
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+
+	var groups [][]int
+	for _, bucket := range buckets {
+		if len(bucket) > 1 {
+			groups = append(groups, bucket)
+		}
+	}
+	return groups
+}
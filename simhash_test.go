@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"math/rand/v2"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const articleText = `The crawler visits every page on a site, extracts the first heading and paragraph,
+collects outgoing links and images, and writes everything it finds to a CSV report so that
+site owners can audit broken links, missing alt text, thin content and duplicated pages
+across hundreds of URLs without clicking through each one by hand.`
+
+func TestSimhashSimilarity(t *testing.T) {
+	tests := []struct {
+		name    string
+		a       string
+		b       string
+		atLeast float64
+		below   float64
+	}{
+		{
+			name:    "identical text",
+			a:       articleText,
+			b:       articleText,
+			atLeast: 1,
+			below:   1.01,
+		},
+		{
+			name:    "case and punctuation ignored",
+			a:       articleText,
+			b:       strings.ToUpper(strings.ReplaceAll(articleText, ",", ";")),
+			atLeast: 1,
+			below:   1.01,
+		},
+		{
+			name:    "differs only in a date",
+			a:       "Published 2024-01-05. " + articleText,
+			b:       "Published 2025-11-30. " + articleText,
+			atLeast: 0.85,
+			below:   1.01,
+		},
+		{
+			name:    "unrelated text",
+			a:       articleText,
+			b:       "Preheat the oven, whisk the eggs with sugar until pale, fold in the flour gently and bake the sponge for twenty five minutes before letting it cool on a wire rack overnight.",
+			atLeast: 0,
+			below:   0.8,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			similarity := simhashSimilarity(simhash(tc.a), simhash(tc.b))
+			if similarity < tc.atLeast || similarity >= tc.below {
+				t.Errorf("Test %v - %s FAIL: expected similarity in [%v, %v), got %v", i, tc.name, tc.atLeast, tc.below, similarity)
+			}
+		})
+	}
+}
+
+func TestSimhashShortText(t *testing.T) {
+	if h := simhash("too short"); h != 0 {
+		t.Errorf("expected 0 for text shorter than a shingle, got %x", h)
+	}
+}
+
+func TestNearDuplicateClusters(t *testing.T) {
+	pages := map[string]PageData{
+		"example.com/a": {SimHash: 0xFFFF0000FFFF0000},
+		"example.com/b": {SimHash: 0xFFFF0000FFFF0001}, // 1 bit from a
+		"example.com/c": {SimHash: 0xFFFF0000FFFF0003}, // 1 bit from b, 2 from a
+		"example.com/d": {SimHash: 0x0000FFFF0000FFFF}, // unrelated
+		"example.com/e": {},                            // too short to fingerprint
+		"example.com/f": {},
+	}
+
+	store := newMemoryStore(pages)
+
+	actual, err := nearDuplicateClusters(store, 1-2.0/64)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []nearDuplicateCluster{
+		{URLs: []string{"example.com/a", "example.com/b", "example.com/c"}, MinSimilarity: 1 - 2.0/64},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+
+	// A stricter threshold only links neighbours one bit apart, which still chains a-b-c together
+	actual, err = nearDuplicateClusters(store, 1-1.0/64)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected = []nearDuplicateCluster{
+		{URLs: []string{"example.com/a", "example.com/b", "example.com/c"}, MinSimilarity: 1 - 1.0/64},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v, got %+v", expected, actual)
+	}
+
+	if clusters, _ := nearDuplicateClusters(store, 1); len(clusters) != 0 {
+		t.Errorf("expected no clusters at threshold 1, got %+v", clusters)
+	}
+}
+
+func TestNearDuplicateClustersMatchesAllPairs(t *testing.T) {
+	// Random hashes, each with a few neighbours a handful of bits away and some exact copies
+	rng := rand.New(rand.NewPCG(1, 2))
+	pages := make(map[string]PageData)
+	var hashes []uint64
+	for i := 0; i < 150; i++ {
+		hash := rng.Uint64()
+		for j := 0; j < 3; j++ {
+			neighbour := hash
+			for flips := rng.IntN(10); flips > 0; flips-- {
+				neighbour ^= 1 << rng.IntN(64)
+			}
+			hashes = append(hashes, neighbour)
+		}
+	}
+	hashes = append(hashes, hashes[0], hashes[1])
+	for i, hash := range hashes {
+		pages[fmt.Sprintf("example.com/%04d", i)] = PageData{SimHash: hash}
+	}
+	store := newMemoryStore(pages)
+
+	for _, threshold := range []float64{0.8, 0.9, 0.95, 1 - 3.0/64, 1} {
+		t.Run(fmt.Sprint(threshold), func(t *testing.T) {
+			actual, err := nearDuplicateClusters(store, threshold)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			expected := allPairsClusters(pages, threshold)
+			if len(expected) == 0 {
+				t.Fatal("expected the test data to have near-duplicates")
+			}
+			if !reflect.DeepEqual(clusterURLs(actual), expected) {
+				t.Errorf("banded clusters differ from comparing every pair:\n got %v\nwant %v", clusterURLs(actual), expected)
+			}
+		})
+	}
+}
+
+// allPairsClusters clusters pages by comparing every pair, as a reference for the banded search
+func allPairsClusters(pages map[string]PageData, threshold float64) [][]string {
+	var urls []string
+	for pageURL := range pages {
+		urls = append(urls, pageURL)
+	}
+	sort.Strings(urls)
+
+	cluster := make(map[string]int)
+	for i, pageURL := range urls {
+		cluster[pageURL] = i
+	}
+	for i := range urls {
+		for j := i + 1; j < len(urls); j++ {
+			distance := bits.OnesCount64(pages[urls[i]].SimHash ^ pages[urls[j]].SimHash)
+			if 1-float64(distance)/64 < threshold {
+				continue
+			}
+			from, to := cluster[urls[j]], cluster[urls[i]]
+			for pageURL, c := range cluster {
+				if c == from {
+					cluster[pageURL] = to
+				}
+			}
+		}
+	}
+
+	members := make(map[int][]string)
+	for _, pageURL := range urls {
+		members[cluster[pageURL]] = append(members[cluster[pageURL]], pageURL)
+	}
+	var clusters [][]string
+	for _, m := range members {
+		if len(m) > 1 {
+			clusters = append(clusters, m)
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i][0] < clusters[j][0] })
+	return clusters
+}
+
+// clusterURLs returns the URLs of each cluster, ordered by first URL
+func clusterURLs(clusters []nearDuplicateCluster) [][]string {
+	var urls [][]string
+	for _, c := range clusters {
+		urls = append(urls, c.URLs)
+	}
+	sort.Slice(urls, func(i, j int) bool { return urls[i][0] < urls[j][0] })
+	return urls
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// sitemapLimits are the sitemap protocol's caps for a single file
+type sitemapLimits struct {
+	URLs  int
+	Bytes int
+}
+
+// defaultSitemapLimits are 50,000 URLs or 50 MB uncompressed per sitemap
+var defaultSitemapLimits = sitemapLimits{URLs: 50000, Bytes: 50 * 1024 * 1024}
+
+const (
+	sitemapHeader = `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n"
+	sitemapFooter = "</urlset>\n"
+)
+
+// includeInSitemap reports whether a page belongs in a sitemap: fetched with a 200 without
+// redirecting, indexable, canonical and not a duplicate of another page
+func includeInSitemap(pageData PageData, policy normalizePolicy) bool {
+	return pageStatus(pageData) == 200 && pageData.FetchError == "" && pageData.RedirectedTo == "" && pageData.DuplicateOf == "" &&
+		!pageData.NoIndex && isCanonical(pageData, policy)
+}
+
+// sitemapLastmod converts a Last-Modified header to the W3C datetime format sitemaps use, "" if unknown
+func sitemapLastmod(lastModified string) string {
+	if lastModified == "" {
+		return ""
+	}
+	t, err := http.ParseTime(lastModified)
+	if err != nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// sitemapLoc returns a page's URL without its fragment and without the tracking and session
+// parameters the crawl's normalization policy strips, keeping the rest of the URL as linked
+func sitemapLoc(pageURL string, policy normalizePolicy) string {
+	parsedURL, err := url.Parse(pageURL)
+	if err != nil {
+		return pageURL
+	}
+	scope := learnerScope(parsedURL)
+	parsedURL.Fragment, parsedURL.RawFragment = "", ""
+
+	if len(policy.StripParams) > 0 {
+		escaped := stripPathParams(parsedURL.EscapedPath(), policy.StripParams)
+		if unescaped, err := url.PathUnescape(escaped); err == nil {
+			parsedURL.Path, parsedURL.RawPath = unescaped, escaped
+		}
+	}
+
+	var kept []string
+	for _, param := range strings.Split(parsedURL.RawQuery, "&") {
+		rawName, _, _ := strings.Cut(param, "=")
+		name, err := url.QueryUnescape(rawName)
+		if param == "" || err == nil && (matchesParam(name, policy.StripParams) || policy.Learner != nil && policy.Learner.isIgnored(scope, name)) {
+			continue
+		}
+		kept = append(kept, param)
+	}
+	parsedURL.RawQuery = strings.Join(kept, "&")
+	return parsedURL.String()
+}
+
+// sitemapEntry formats one <url> element
+func sitemapEntry(loc, lastmod string) string {
+	var b strings.Builder
+	b.WriteString("  <url>\n    <loc>")
+	xml.EscapeText(&b, []byte(loc))
+	b.WriteString("</loc>\n")
+	if lastmod != "" {
+		b.WriteString("    <lastmod>" + lastmod + "</lastmod>\n")
+	}
+	b.WriteString("  </url>\n")
+	return b.String()
+}
+
+// writeSitemaps writes sitemap.xml into dir from the indexable pages, judging canonicals by
+// the crawl's normalization policy. Past the protocol's limits, pages are split across
+// sitemap-1.xml, sitemap-2.xml, ... and sitemap.xml becomes a sitemap index pointing at them
+// under siteURL. Returns the files written.
+func writeSitemaps(pages pageStore, policy normalizePolicy, dir, siteURL string) ([]string, error) {
+	return writeSitemapsWithLimits(pages, policy, dir, siteURL, defaultSitemapLimits)
+}
+
+// writeSitemapsWithLimits is writeSitemaps with configurable per-file limits
+func writeSitemapsWithLimits(pages pageStore, policy normalizePolicy, dir, siteURL string, limits sitemapLimits) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	var parts []string
+	var file *os.File
+	var writer *bufio.Writer
+	var urls, size int
+
+	closePart := func() error {
+		if file == nil {
+			return nil
+		}
+		if _, err := writer.WriteString(sitemapFooter); err != nil {
+			file.Close()
+			return err
+		}
+		if err := writer.Flush(); err != nil {
+			file.Close()
+			return err
+		}
+		err := file.Close()
+		file = nil
+		return err
+	}
+	openPart := func() error {
+		name := fmt.Sprintf("sitemap-%d.xml", len(parts)+1)
+		f, err := os.Create(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+		file, writer = f, bufio.NewWriter(f)
+		parts = append(parts, name)
+		urls, size = 0, len(sitemapHeader)+len(sitemapFooter)
+		_, err = writer.WriteString(sitemapHeader)
+		return err
+	}
+
+	err := pages.each(func(_ string, pageData PageData) error {
+		if !includeInSitemap(pageData, policy) {
+			return nil
+		}
+		entry := sitemapEntry(sitemapLoc(pageData.URL, policy), sitemapLastmod(pageData.LastModified))
+		if file == nil || urls >= limits.URLs || size+len(entry) > limits.Bytes {
+			if err := closePart(); err != nil {
+				return err
+			}
+			if err := openPart(); err != nil {
+				return err
+			}
+		}
+		urls++
+		size += len(entry)
+		_, err := writer.WriteString(entry)
+		return err
+	})
+	if err == nil {
+		err = closePart()
+	}
+	if err != nil {
+		if file != nil {
+			file.Close()
+		}
+		return nil, err
+	}
+
+	sitemapPath := filepath.Join(dir, "sitemap.xml")
+	switch len(parts) {
+	case 0:
+		// Still write a valid, empty sitemap
+		return []string{sitemapPath}, os.WriteFile(sitemapPath, []byte(sitemapHeader+sitemapFooter), 0o644)
+	case 1:
+		return []string{sitemapPath}, os.Rename(filepath.Join(dir, parts[0]), sitemapPath)
+	}
+
+	var index strings.Builder
+	index.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	index.WriteString(`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` + "\n")
+	written := make([]string, 0, len(parts)+1)
+	for _, part := range parts {
+		index.WriteString("  <sitemap>\n    <loc>")
+		xml.EscapeText(&index, []byte(strings.TrimSuffix(siteURL, "/")+"/"+part))
+		index.WriteString("</loc>\n  </sitemap>\n")
+		written = append(written, filepath.Join(dir, part))
+	}
+	index.WriteString("</sitemapindex>\n")
+	if err := os.WriteFile(sitemapPath, []byte(index.String()), 0o644); err != nil {
+		return nil, err
+	}
+	return append([]string{sitemapPath}, written...), nil
+}
+
+// siteRoot returns the scheme and host of the first crawled page, as the root URL of the site
+func siteRoot(pages pageStore) (string, error) {
+	var root string
+	err := pages.each(func(_ string, pageData PageData) error {
+		if root != "" {
+			return nil
+		}
+		if u, err := url.Parse(pageData.URL); err == nil && u.Host != "" {
+			root = u.Scheme + "://" + u.Host + "/"
+		}
+		return nil
+	})
+	if err == nil && root == "" {
+		err = fmt.Errorf("no crawled pages to take the site URL from")
+	}
+	return root, err
+}
+
+// runSitemap implements "crawler sitemap [flags] <pages.json>" and returns the exit code
+func runSitemap(args []string) int {
+	flags := flag.NewFlagSet("sitemap", flag.ContinueOnError)
+	outputDir := flags.String("output", ".", "directory to write sitemap.xml into")
+	siteURL := flags.String("site-url", "", "URL the sitemap files will be served from, for the sitemap index (default: the crawled site's root)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: crawler sitemap [flags] <pages.json>")
+		fmt.Fprintln(flags.Output(), "Writes a sitemap of the indexable, canonical pages of a saved crawl.")
+		flags.PrintDefaults()
+	}
+	positional, err := parseArgs(flags, args)
+	if err != nil {
+		return parseExitCode(err)
+	}
+	if len(positional) != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	pages := newMemoryStore(nil)
+	policy, err := loadJSONReport(positional[0], pages)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error loading crawl: %v\n", err)
+		return exitCrawlError
+	}
+	root := *siteURL
+	if root == "" {
+		root, err = siteRoot(pages)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitCrawlError
+		}
+	}
+
+	files, err := writeSitemaps(pages, policy, *outputDir, root)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error writing sitemap: %v\n", err)
+		return exitCrawlError
+	}
+	fmt.Printf("Sitemap written to: %s\n", strings.Join(files, ", "))
+	return exitOK
+}
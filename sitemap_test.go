@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sitemapLocs parses a sitemap or sitemap index and returns its <loc> values
+func sitemapLocs(t *testing.T, filename string) (root string, locs []string) {
+	t.Helper()
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", filename, err)
+	}
+	var doc struct {
+		XMLName xml.Name
+		Locs    []string `xml:"url>loc"`
+		Index   []string `xml:"sitemap>loc"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("invalid XML in %s: %v", filename, err)
+	}
+	return doc.XMLName.Local, append(doc.Locs, doc.Index...)
+}
+
+func TestWriteSitemapsFiltersPages(t *testing.T) {
+	pages := newMemoryStore(map[string]PageData{
+		"example.com":              {URL: "https://example.com", StatusCode: 200, LastModified: "Wed, 01 May 2024 12:00:00 GMT"},
+		"example.com/search?q=a&b": {URL: "https://example.com/search?q=a&b", StatusCode: 200},
+		"example.com/missing":      {URL: "https://example.com/missing", StatusCode: 404, FetchError: "error status code: 404"},
+		"example.com/private":      {URL: "https://example.com/private", StatusCode: 200, NoIndex: true},
+		"example.com/print":        {URL: "https://example.com/print", StatusCode: 200, Canonical: "https://example.com"},
+		"example.com/old":          {URL: "https://example.com/old", StatusCode: 200, RedirectedTo: "https://example.com/new"},
+		"example.com/copy":         {URL: "https://example.com/copy", StatusCode: 200, DuplicateOf: "example.com"},
+	})
+
+	dir := t.TempDir()
+	files, err := writeSitemaps(pages, normalizePolicy{}, dir, "https://example.com/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(files, []string{filepath.Join(dir, "sitemap.xml")}) {
+		t.Fatalf("expected a single sitemap.xml, got %v", files)
+	}
+
+	root, locs := sitemapLocs(t, files[0])
+	if root != "urlset" {
+		t.Errorf("expected a urlset, got %s", root)
+	}
+	if !reflect.DeepEqual(locs, []string{"https://example.com", "https://example.com/search?q=a&b"}) {
+		t.Errorf("unexpected sitemap URLs: %v", locs)
+	}
+
+	data, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(data), "<lastmod>2024-05-01T12:00:00Z</lastmod>") {
+		t.Errorf("expected lastmod from Last-Modified, got:\n%s", data)
+	}
+	if !strings.Contains(string(data), "q=a&amp;b") {
+		t.Errorf("expected & to be escaped, got:\n%s", data)
+	}
+}
+
+func TestWriteSitemapsCleansURLs(t *testing.T) {
+	learner := newParamLearner()
+	learner.observe("https://example.com/docs?page=2&visit=a", "hash")
+	learner.observe("https://example.com/docs?page=2&visit=b", "hash")
+	policy := normalizePolicy{Query: queryKeepAll, StripParams: defaultTrackingParams, Learner: learner}
+
+	pages := newMemoryStore(nil)
+	for _, pageURL := range []string{
+		"https://example.com/blog?utm_source=news&id=7&utm_medium=email#comments",
+		"https://example.com/docs?page=2&visit=a",
+		"https://example.com/cart;jsessionid=ABC123?step=1",
+		"https://example.com/about#team",
+	} {
+		key, err := policy.normalize(pageURL)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		pages.put(key, PageData{URL: pageURL, StatusCode: 200})
+	}
+
+	files, err := writeSitemaps(pages, policy, t.TempDir(), "https://example.com/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, locs := sitemapLocs(t, files[0])
+	expected := []string{
+		"https://example.com/about",
+		"https://example.com/blog?id=7",
+		"https://example.com/cart?step=1",
+		"https://example.com/docs?page=2",
+	}
+	if !reflect.DeepEqual(locs, expected) {
+		t.Errorf("expected %v, got %v", expected, locs)
+	}
+}
+
+func TestWriteSitemapsSplitsIntoIndex(t *testing.T) {
+	pageMap := make(map[string]PageData)
+	for i := 0; i < 5; i++ {
+		pageURL := fmt.Sprintf("https://example.com/p%d", i)
+		pageMap[strings.TrimPrefix(pageURL, "https://")] = PageData{URL: pageURL, StatusCode: 200}
+	}
+
+	dir := t.TempDir()
+	files, err := writeSitemapsWithLimits(newMemoryStore(pageMap), normalizePolicy{}, dir, "https://example.com/sitemaps", sitemapLimits{URLs: 2, Bytes: 1 << 20})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 4 {
+		t.Fatalf("expected an index and 3 sitemaps, got %v", files)
+	}
+
+	root, locs := sitemapLocs(t, filepath.Join(dir, "sitemap.xml"))
+	expectedIndex := []string{
+		"https://example.com/sitemaps/sitemap-1.xml",
+		"https://example.com/sitemaps/sitemap-2.xml",
+		"https://example.com/sitemaps/sitemap-3.xml",
+	}
+	if root != "sitemapindex" || !reflect.DeepEqual(locs, expectedIndex) {
+		t.Errorf("unexpected index %s: %v", root, locs)
+	}
+
+	var all []string
+	for _, part := range []string{"sitemap-1.xml", "sitemap-2.xml", "sitemap-3.xml"} {
+		_, locs := sitemapLocs(t, filepath.Join(dir, part))
+		all = append(all, locs...)
+	}
+	if len(all) != 5 {
+		t.Errorf("expected all 5 pages across the sitemaps, got %v", all)
+	}
+}
+
+func TestWriteSitemapsSplitsBySize(t *testing.T) {
+	pageMap := map[string]PageData{
+		"example.com/a": {URL: "https://example.com/a", StatusCode: 200},
+		"example.com/b": {URL: "https://example.com/b", StatusCode: 200},
+	}
+	entrySize := len(sitemapEntry("https://example.com/a", ""))
+	limits := sitemapLimits{URLs: 100, Bytes: len(sitemapHeader) + len(sitemapFooter) + entrySize}
+
+	files, err := writeSitemapsWithLimits(newMemoryStore(pageMap), normalizePolicy{}, t.TempDir(), "https://example.com", limits)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 3 {
+		t.Errorf("expected an index and one sitemap per page, got %v", files)
+	}
+}
+
+func TestWriteSitemapsEmpty(t *testing.T) {
+	dir := t.TempDir()
+	files, err := writeSitemaps(newMemoryStore(nil), normalizePolicy{}, dir, "https://example.com/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	root, locs := sitemapLocs(t, files[0])
+	if root != "urlset" || len(locs) != 0 {
+		t.Errorf("expected an empty urlset, got %s %v", root, locs)
+	}
+}
+
+func TestRunSitemap(t *testing.T) {
+	dir := t.TempDir()
+	pages := map[string]PageData{
+		"example.com":         {URL: "https://example.com/"},
+		"example.com/about":   {URL: "https://example.com/about"},
+		"example.com/private": {URL: "https://example.com/private", NoIndex: true},
+		// The crawl kept queries, so page 2 of the list is not its own canonical
+		"example.com/list?page=2": {URL: "https://example.com/list?page=2", Canonical: "https://example.com/list"},
+	}
+	pagesFile := filepath.Join(dir, "pages.json")
+	if err := writeJSONReport(newMemoryStore(pages), normalizePolicy{Query: queryKeepAll}, pagesFile); err != nil {
+		t.Fatal(err)
+	}
+
+	outputDir := filepath.Join(dir, "out")
+	if code := runSitemap([]string{pagesFile, "-output", outputDir}); code != exitOK {
+		t.Fatalf("runSitemap exited %d", code)
+	}
+	root, locs := sitemapLocs(t, filepath.Join(outputDir, "sitemap.xml"))
+	expected := []string{"https://example.com/", "https://example.com/about"}
+	if root != "urlset" || !reflect.DeepEqual(locs, expected) {
+		t.Errorf("expected urlset %v, got %s %v", expected, root, locs)
+	}
+
+	if code := runSitemap(nil); code != exitUsage {
+		t.Errorf("runSitemap without a file exited %d, want %d", code, exitUsage)
+	}
+	if code := runSitemap([]string{filepath.Join(dir, "missing.json")}); code != exitCrawlError {
+		t.Errorf("runSitemap with a missing file exited %d, want %d", code, exitCrawlError)
+	}
+}
+
+func TestSiteRoot(t *testing.T) {
+	root, err := siteRoot(newMemoryStore(map[string]PageData{
+		"example.com/b": {URL: "https://example.com/b"},
+		"example.com/a": {URL: "https://example.com/a?x=1"},
+	}))
+	if err != nil || root != "https://example.com/" {
+		t.Errorf("expected https://example.com/, got %q (%v)", root, err)
+	}
+
+	if _, err := siteRoot(newMemoryStore(nil)); err == nil {
+		t.Error("expected an error for an empty crawl")
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"database/sql"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema creates the report tables. Every run of the crawler adds a row to crawl_runs,
+// so one database can hold several crawls for comparison.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS crawl_runs (
+	id          INTEGER PRIMARY KEY,
+	base_url    TEXT NOT NULL,
+	started_at  TEXT NOT NULL,
+	finished_at TEXT NOT NULL,
+	page_count  INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS pages (
+	id                    INTEGER PRIMARY KEY,
+	run_id                INTEGER NOT NULL REFERENCES crawl_runs(id),
+	url                   TEXT NOT NULL,
+	fetched_url           TEXT NOT NULL,
+	status_code           INTEGER,
+	fetch_error           TEXT NOT NULL,
+	depth                 INTEGER NOT NULL,
+	title                 TEXT NOT NULL,
+	h1                    TEXT NOT NULL,
+	first_paragraph       TEXT NOT NULL,
+	word_count            INTEGER NOT NULL,
+	reading_minutes       INTEGER NOT NULL,
+	charset               TEXT NOT NULL,
+	declared_content_type TEXT NOT NULL,
+	content_hash          TEXT NOT NULL,
+	duplicate_of          TEXT NOT NULL,
+	UNIQUE (run_id, url)
+);
+CREATE TABLE IF NOT EXISTS links (
+	page_id        INTEGER NOT NULL REFERENCES pages(id),
+	kind           TEXT NOT NULL,
+	target_url     TEXT NOT NULL,
+	target_key     TEXT,                        -- normalized like pages.url; NULL unless navigable
+	target_page_id INTEGER REFERENCES pages(id) -- the crawled page linked to, if any
+);
+CREATE TABLE IF NOT EXISTS images (
+	page_id INTEGER NOT NULL REFERENCES pages(id),
+	src     TEXT NOT NULL,
+	alt     TEXT,
+	width   TEXT NOT NULL,
+	height  TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS assets (
+	page_id      INTEGER NOT NULL REFERENCES pages(id),
+	url          TEXT NOT NULL,
+	kind         TEXT NOT NULL,
+	status_code  INTEGER,
+	content_type TEXT NOT NULL,
+	size_bytes   INTEGER,
+	error        TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS pages_content_hash ON pages (run_id, content_hash);
+CREATE INDEX IF NOT EXISTS links_page ON links (page_id);
+CREATE INDEX IF NOT EXISTS links_target ON links (target_url);
+CREATE INDEX IF NOT EXISTS links_target_key ON links (target_key);
+CREATE INDEX IF NOT EXISTS links_target_page ON links (target_page_id);
+CREATE INDEX IF NOT EXISTS images_page ON images (page_id);
+CREATE INDEX IF NOT EXISTS images_src ON images (src);
+CREATE INDEX IF NOT EXISTS assets_page ON assets (page_id);
+CREATE INDEX IF NOT EXISTS assets_url ON assets (url);
+`
+
+// crawlRun describes one crawl for the crawl_runs table
+type crawlRun struct {
+	BaseURL    string
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// writeSQLiteReport adds a crawl run and its pages, links, images and assets to a SQLite database,
+// creating the tables if needed. Navigable links are normalized with the crawl's policy, so
+// they join to the pages they point at. The whole run is written in one transaction.
+func writeSQLiteReport(pages pageStore, run crawlRun, policy normalizePolicy, filename string) error {
+	db, err := sql.Open("sqlite", filename)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`INSERT INTO crawl_runs (base_url, started_at, finished_at, page_count) VALUES (?, ?, ?, ?)`,
+		run.BaseURL, run.StartedAt.UTC().Format(time.RFC3339), run.FinishedAt.UTC().Format(time.RFC3339), pages.count(),
+	)
+	if err != nil {
+		return err
+	}
+	runID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	insertPage, err := tx.Prepare(`INSERT INTO pages (run_id, url, fetched_url, status_code, fetch_error, depth, title, h1,
+		first_paragraph, word_count, reading_minutes, charset, declared_content_type, content_hash, duplicate_of)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	insertLink, err := tx.Prepare(`INSERT INTO links (page_id, kind, target_url, target_key) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	insertImage, err := tx.Prepare(`INSERT INTO images (page_id, src, alt, width, height) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	insertAsset, err := tx.Prepare(`INSERT INTO assets (page_id, url, kind, status_code, content_type, size_bytes, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+
+	err = pages.each(func(pageURL string, pageData PageData) error {
+		// Pages that failed before a response have no status
+		var statusCode sql.NullInt64
+		if pageData.StatusCode != 0 {
+			statusCode = sql.NullInt64{Int64: int64(pageData.StatusCode), Valid: true}
+		}
+		result, err := insertPage.Exec(runID, displayURL(pageURL), pageData.URL, statusCode, pageData.FetchError,
+			pageData.Depth, pageData.Title, pageData.H1, pageData.FirstParagraph, pageData.WordCount,
+			pageData.ReadingMinutes, pageData.Charset, pageData.DeclaredContentType, pageData.ContentHash,
+			displayURL(pageData.DuplicateOf))
+		if err != nil {
+			return err
+		}
+		pageID, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		links := []struct {
+			kind    string
+			targets []string
+		}{
+			{"navigable", pageData.OutgoingLinks},
+			{"email", pageData.Emails},
+			{"phone", pageData.Phones},
+			{"script", pageData.ScriptLinks},
+			{"other", pageData.OtherLinks},
+		}
+		for _, group := range links {
+			for _, target := range group.targets {
+				var targetKey sql.NullString
+				if group.kind == "navigable" {
+					if key, err := policy.normalize(target); err == nil {
+						targetKey = sql.NullString{String: displayURL(key), Valid: true}
+					}
+				}
+				if _, err := insertLink.Exec(pageID, group.kind, target, targetKey); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, image := range pageData.Images {
+			// A missing alt attribute is NULL, distinct from an empty alt=""
+			var alt sql.NullString
+			alt.String, alt.Valid = image.Alt, image.HasAlt
+			if _, err := insertImage.Exec(pageID, image.Src, alt, image.Width, image.Height); err != nil {
+				return err
+			}
+		}
+
+		for _, asset := range pageData.Assets {
+			// Unchecked assets have no status or size
+			var statusCode, size sql.NullInt64
+			if asset.StatusCode != 0 {
+				statusCode = sql.NullInt64{Int64: int64(asset.StatusCode), Valid: true}
+			}
+			if asset.Size != 0 {
+				size = sql.NullInt64{Int64: asset.Size, Valid: true}
+			}
+			if _, err := insertAsset.Exec(pageID, asset.URL, string(asset.Kind), statusCode, asset.ContentType, size, asset.Error); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// With every page of the run inserted, point links at the pages they reach
+	_, err = tx.Exec(`UPDATE links SET target_page_id = (
+			SELECT id FROM pages WHERE pages.run_id = ? AND pages.url = links.target_key
+		) WHERE target_key IS NOT NULL AND page_id IN (SELECT id FROM pages WHERE run_id = ?)`, runID, runID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
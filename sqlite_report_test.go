@@ -0,0 +1,145 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWriteSQLiteReport(t *testing.T) {
+	pages := newMemoryStore(map[string]PageData{
+		"example.com": {
+			URL:           "https://example.com",
+			StatusCode:    200,
+			Title:         "Example",
+			H1:            "Home",
+			OutgoingLinks: []string{"https://example.com/about/", "https://example.com/blog", "https://other.example/"},
+			Emails:        []string{"hello@example.com"},
+			Images: []ImageInfo{
+				{Src: "https://example.com/logo.png", Alt: "Logo", HasAlt: true},
+				{Src: "https://example.com/spacer.gif"},
+			},
+			Assets: []Asset{{URL: "https://example.com/app.js", Kind: assetScript, StatusCode: 200, Size: 512}},
+		},
+		"example.com/about": {
+			URL:           "https://example.com/about",
+			StatusCode:    200,
+			Depth:         1,
+			H1:            "About",
+			OutgoingLinks: []string{"https://example.com/blog"},
+		},
+		"example.com/blog": {
+			URL:        "https://example.com/blog",
+			Depth:      1,
+			FetchError: "connection refused",
+		},
+	})
+	run := crawlRun{
+		BaseURL:    "https://example.com",
+		StartedAt:  time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		FinishedAt: time.Date(2024, 5, 1, 12, 5, 0, 0, time.UTC),
+	}
+
+	filename := filepath.Join(t.TempDir(), "crawl.db")
+	if err := writeSQLiteReport(pages, run, normalizePolicy{}, filename); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// A second run is added alongside the first
+	if err := writeSQLiteReport(pages, run, normalizePolicy{}, filename); err != nil {
+		t.Fatalf("unexpected error on second run: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", filename)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	var runs, pageCount int
+	var startedAt string
+	if err := db.QueryRow(`SELECT COUNT(*), MAX(page_count), MAX(started_at) FROM crawl_runs`).Scan(&runs, &pageCount, &startedAt); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if runs != 2 || pageCount != 3 || startedAt != "2024-05-01T12:00:00Z" {
+		t.Errorf("unexpected crawl_runs: %d runs, page_count %d, started_at %q", runs, pageCount, startedAt)
+	}
+
+	// The query the semicolon-joined CSV columns made painful: which pages link to X?
+	rows, err := db.Query(`SELECT p.url FROM links l JOIN pages p ON p.id = l.page_id
+		WHERE p.run_id = 1 AND l.kind = 'navigable' AND l.target_url = ? ORDER BY p.url`, "https://example.com/blog")
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	defer rows.Close()
+	var linking []string
+	for rows.Next() {
+		var pageURL string
+		if err := rows.Scan(&pageURL); err != nil {
+			t.Fatalf("scan failed: %v", err)
+		}
+		linking = append(linking, pageURL)
+	}
+	if !reflect.DeepEqual(linking, []string{"example.com", "example.com/about"}) {
+		t.Errorf("unexpected pages linking to /blog: %v", linking)
+	}
+
+	// Links join to the pages they reach, however the link was written
+	var reachingAbout int
+	err = db.QueryRow(`SELECT COUNT(*) FROM links l JOIN pages p ON p.id = l.page_id JOIN pages t ON t.id = l.target_page_id
+		WHERE p.run_id = 1 AND t.url = 'example.com/about'`).Scan(&reachingAbout)
+	if err != nil || reachingAbout != 1 {
+		t.Errorf("expected the trailing-slash link to reach example.com/about, got %d links (err %v)", reachingAbout, err)
+	}
+	var unresolved int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM links WHERE kind = 'navigable' AND target_page_id IS NULL`).Scan(&unresolved); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if unresolved != 2 {
+		t.Errorf("expected only the off-site link of each run to be unresolved, got %d", unresolved)
+	}
+
+	// Fetch results are queryable without reading the CSV
+	rows, err = db.Query(`SELECT url, status_code, fetch_error, depth, title FROM pages WHERE run_id = 1 ORDER BY url`)
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	defer rows.Close()
+	var fetched []string
+	for rows.Next() {
+		var pageURL, fetchError, title string
+		var depth int
+		var status sql.NullInt64
+		if err := rows.Scan(&pageURL, &status, &fetchError, &depth, &title); err != nil {
+			t.Fatalf("scan failed: %v", err)
+		}
+		fetched = append(fetched, fmt.Sprintf("%s %v %q %d %q", pageURL, status, fetchError, depth, title))
+	}
+	expectedFetched := []string{
+		`example.com {200 true} "" 0 "Example"`,
+		`example.com/about {200 true} "" 1 ""`,
+		`example.com/blog {0 false} "connection refused" 1 ""`,
+	}
+	if !reflect.DeepEqual(fetched, expectedFetched) {
+		t.Errorf("expected pages %v, got %v", expectedFetched, fetched)
+	}
+
+	var missingAlt int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM images i JOIN pages p ON p.id = i.page_id WHERE p.run_id = 1 AND i.alt IS NULL`).Scan(&missingAlt); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if missingAlt != 1 {
+		t.Errorf("expected 1 image without alt, got %d", missingAlt)
+	}
+
+	var kind string
+	var status, size sql.NullInt64
+	if err := db.QueryRow(`SELECT kind, status_code, size_bytes FROM assets LIMIT 1`).Scan(&kind, &status, &size); err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+	if kind != "script" || status.Int64 != 200 || size.Int64 != 512 {
+		t.Errorf("unexpected asset row: %s %v %v", kind, status, size)
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+// pageStore holds the crawled pages by normalized URL, and the queue of URLs waiting to be
+// crawled. Implementations are safe for concurrent use.
+type pageStore interface {
+	// put stores the data for a crawled URL
+	put(normalizedURL string, pageData PageData) error
+	// putAll stores several pages at once, for loading pages in bulk
+	putAll(pages map[string]PageData) error
+	// get returns the data stored for a URL
+	get(normalizedURL string) (PageData, bool, error)
+	// remove forgets a URL so it can be queued again
+	remove(normalizedURL string) error
+	// count returns the number of stored and queued URLs
+	count() int
+	// each calls fn for every page in URL order, stopping at the first error
+	each(fn func(normalizedURL string, pageData PageData) error) error
+	// enqueue stores a placeholder for each URL not stored yet and queues it to be crawled,
+	// reporting which were new
+	enqueue(urls []queuedURL) (added []bool, err error)
+	// queued returns up to limit queued URLs in queue order, starting at position from
+	queued(from uint64, limit int) ([]queuedURL, error)
+	// dequeue removes a crawled URL from the queue
+	dequeue(seq uint64) error
+	// queueLen returns the number of queued URLs
+	queueLen() int
+	// claimContent records normalizedURL as the first page with a content hash, unless one
+	// was recorded already, and returns the first page
+	claimContent(contentHash, normalizedURL string) (string, error)
+	close() error
+}
+
+// queuedURL is a URL waiting to be crawled
+type queuedURL struct {
+	Key   string `json:"key"` // normalized URL the page is stored under
+	URL   string `json:"url"`
+	Depth int    `json:"depth"` // links from the start page
+	seq   uint64 // position in the queue
+}
+
+// memoryStore keeps every page in a map, which is fastest but bounded by RAM
+type memoryStore struct {
+	mu      sync.Mutex
+	pages   map[string]PageData
+	queue   map[uint64]queuedURL
+	nextSeq uint64
+	content map[string]string // content hash -> first URL
+}
+
+// newMemoryStore creates a memoryStore, optionally seeded with pages
+func newMemoryStore(pages map[string]PageData) *memoryStore {
+	if pages == nil {
+		pages = make(map[string]PageData)
+	}
+	return &memoryStore{
+		pages:   pages,
+		queue:   make(map[uint64]queuedURL),
+		content: make(map[string]string),
+	}
+}
+
+func (s *memoryStore) put(normalizedURL string, pageData PageData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pages[normalizedURL] = pageData
+	return nil
+}
+
+func (s *memoryStore) putAll(pages map[string]PageData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for pageURL, pageData := range pages {
+		s.pages[pageURL] = pageData
+	}
+	return nil
+}
+
+func (s *memoryStore) get(normalizedURL string) (PageData, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pageData, ok := s.pages[normalizedURL]
+	return pageData, ok, nil
+}
+
+func (s *memoryStore) remove(normalizedURL string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pages, normalizedURL)
+	return nil
+}
+
+func (s *memoryStore) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pages)
+}
+
+func (s *memoryStore) each(fn func(string, PageData) error) error {
+	s.mu.Lock()
+	urls := make([]string, 0, len(s.pages))
+	for pageURL := range s.pages {
+		urls = append(urls, pageURL)
+	}
+	s.mu.Unlock()
+	sort.Strings(urls)
+
+	for _, pageURL := range urls {
+		pageData, ok, _ := s.get(pageURL)
+		if !ok {
+			continue
+		}
+		if err := fn(pageURL, pageData); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) enqueue(urls []queuedURL) ([]bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	added := make([]bool, len(urls))
+	for i, u := range urls {
+		if _, exists := s.pages[u.Key]; exists {
+			continue
+		}
+		s.pages[u.Key] = PageData{}
+		u.seq = s.nextSeq
+		s.queue[u.seq] = u
+		s.nextSeq++
+		added[i] = true
+	}
+	return added, nil
+}
+
+func (s *memoryStore) queued(from uint64, limit int) ([]queuedURL, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var urls []queuedURL
+	for seq := from; seq < s.nextSeq && len(urls) < limit; seq++ {
+		if u, ok := s.queue[seq]; ok {
+			urls = append(urls, u)
+		}
+	}
+	return urls, nil
+}
+
+func (s *memoryStore) dequeue(seq uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.queue, seq)
+	return nil
+}
+
+func (s *memoryStore) queueLen() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+func (s *memoryStore) claimContent(contentHash, normalizedURL string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if original, ok := s.content[contentHash]; ok {
+		return original, nil
+	}
+	s.content[contentHash] = normalizedURL
+	return normalizedURL, nil
+}
+
+// snapshot returns the finished pages and the queue at one instant, so every link of a
+// finished page is either crawled or still queued
+func (s *memoryStore) snapshot() (map[string]PageData, []queuedURL) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pages := make(map[string]PageData)
+	for pageURL, pageData := range s.pages {
+		if pageData.URL != "" {
+			pages[pageURL] = pageData
+		}
+	}
+	queue := make([]queuedURL, 0, len(s.queue))
+	for _, u := range s.queue {
+		queue = append(queue, u)
+	}
+	sort.Slice(queue, func(i, j int) bool { return queue[i].seq < queue[j].seq })
+	return pages, queue
+}
+
+func (s *memoryStore) close() error {
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// testPageStore checks the behavior every pageStore implementation must share
+func testPageStore(t *testing.T, store pageStore) {
+	t.Helper()
+
+	page := PageData{
+		URL:           "https://example.com/b",
+		H1:            "B",
+		OutgoingLinks: []string{"https://example.com/a"},
+		Outline:       getHeadingOutlineFromHTML("<h1>B</h1>"),
+		SimHash:       1<<63 + 1,
+	}
+	if err := store.put("example.com/b", page); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := store.put("example.com/a", PageData{URL: "https://example.com/a"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, ok, err := store.get("example.com/b"); err != nil || !ok || !reflect.DeepEqual(got, page) {
+		t.Errorf("expected %+v, got %+v (found %v, err %v)", page, got, ok, err)
+	}
+	if _, ok, _ := store.get("example.com/missing"); ok {
+		t.Error("expected missing URL to not be found")
+	}
+	if store.count() != 2 {
+		t.Errorf("expected 2 pages, got %d", store.count())
+	}
+
+	var urls []string
+	err := store.each(func(pageURL string, pageData PageData) error {
+		urls = append(urls, pageURL)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(urls, []string{"example.com/a", "example.com/b"}) {
+		t.Errorf("expected pages in URL order, got %v", urls)
+	}
+
+	if err := store.remove("example.com/a"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if store.count() != 1 {
+		t.Errorf("expected 1 page after remove, got %d", store.count())
+	}
+
+	// Only URLs not stored yet are queued, in the order given; a removed URL can be queued again
+	added, err := store.enqueue([]queuedURL{
+		{Key: "example.com/c", URL: "https://example.com/c", Depth: 1},
+		{Key: "example.com/b", URL: "https://example.com/b", Depth: 1},
+		{Key: "example.com/d", URL: "https://example.com/d", Depth: 2},
+		{Key: "example.com/c", URL: "https://example.com/c#top", Depth: 1},
+		{Key: "example.com/a", URL: "https://example.com/a", Depth: 2},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(added, []bool{true, false, true, false, true}) {
+		t.Errorf("expected only new URLs to be queued, got %v", added)
+	}
+	if pageData, ok, _ := store.get("example.com/c"); !ok || !reflect.DeepEqual(pageData, PageData{}) {
+		t.Errorf("expected an empty placeholder for a queued URL, got %+v, %v", pageData, ok)
+	}
+	if store.queueLen() != 3 {
+		t.Errorf("expected 3 queued URLs, got %d", store.queueLen())
+	}
+	queue, err := store.queued(0, 10)
+	if err != nil || len(queue) != 3 || queue[0].URL != "https://example.com/c" || queue[1].Depth != 2 {
+		t.Fatalf("expected /c, /d at depth 2, then /a, got %+v (err %v)", queue, err)
+	}
+	if rest, _ := store.queued(queue[1].seq, 10); len(rest) != 2 || rest[0].Key != "example.com/d" {
+		t.Errorf("expected reading from a position to skip earlier URLs, got %+v", rest)
+	}
+	if err := store.dequeue(queue[0].seq); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rest, _ := store.queued(0, 10); store.queueLen() != 2 || len(rest) != 2 || rest[0].Key != "example.com/d" {
+		t.Errorf("expected /d and /a left in the queue, got %+v", rest)
+	}
+
+	// The first page to claim a content hash keeps it
+	for _, pageURL := range []string{"example.com/b", "example.com/c"} {
+		if original, err := store.claimContent("hash", pageURL); err != nil || original != "example.com/b" {
+			t.Errorf("expected example.com/b to own the content hash, got %q (err %v)", original, err)
+		}
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testPageStore(t, newMemoryStore(nil))
+}
+
+func TestBoltStore(t *testing.T) {
+	store, err := openBoltStore(filepath.Join(t.TempDir(), "pages.db"), false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer store.close()
+
+	testPageStore(t, store)
+}
+
+func TestBoltStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pages.db")
+
+	store, err := openBoltStore(path, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	store.put("example.com", PageData{URL: "https://example.com", H1: "Home"})
+	store.enqueue([]queuedURL{{Key: "example.com/a", URL: "https://example.com/a", Depth: 1}})
+	store.close()
+
+	// Keeping the pages, as a resumed crawl does
+	store, err = openBoltStore(path, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pageData, ok, _ := store.get("example.com"); !ok || pageData.H1 != "Home" || store.count() != 2 {
+		t.Errorf("expected stored page to survive reopening, got %+v (count %d)", pageData, store.count())
+	}
+	if queue, _ := store.queued(0, 10); store.queueLen() != 1 || len(queue) != 1 || queue[0].URL != "https://example.com/a" {
+		t.Errorf("expected the queue to survive reopening, got %+v", queue)
+	}
+	store.close()
+
+	// Starting fresh
+	store, err = openBoltStore(path, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer store.close()
+	if store.count() != 0 || store.queueLen() != 0 {
+		t.Errorf("expected a fresh crawl to start empty, got %d pages and %d queued", store.count(), store.queueLen())
+	}
+}
+
+func TestMemoryStoreConcurrentEnqueues(t *testing.T) {
+	store := newMemoryStore(nil)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	firsts := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			added, _ := store.enqueue([]queuedURL{{Key: "example.com", URL: "https://example.com"}})
+			if added[0] {
+				mu.Lock()
+				firsts++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firsts != 1 {
+		t.Errorf("expected the URL to be queued exactly once, got %d", firsts)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// SchemaItem is a typed entity declared on a page through JSON-LD, Microdata or RDFa
+type SchemaItem struct {
+	Source     string   `json:"source"` // "json-ld", "microdata" or "rdfa"
+	Type       string   `json:"type"`
+	Properties []string `json:"properties"`
+}
+
+// StructuredData holds the machine-readable metadata found on a page
+type StructuredData struct {
+	Items        []SchemaItem      `json:"items,omitempty"`
+	JSONLDErrors []string          `json:"jsonld_errors,omitempty"`
+	OpenGraph    map[string]string `json:"opengraph,omitempty"`
+	TwitterCard  map[string]string `json:"twitter_card,omitempty"`
+}
+
+// requiredProperties lists the properties search engines expect for common schema.org types
+var requiredProperties = map[string][]string{
+	"Article":        {"headline", "author", "datePublished"},
+	"NewsArticle":    {"headline", "author", "datePublished"},
+	"BlogPosting":    {"headline", "author", "datePublished"},
+	"Product":        {"name", "offers"},
+	"Organization":   {"name", "url"},
+	"LocalBusiness":  {"name", "address"},
+	"Person":         {"name"},
+	"WebSite":        {"name", "url"},
+	"BreadcrumbList": {"itemListElement"},
+	"Event":          {"name", "startDate", "location"},
+	"Recipe":         {"name", "image"},
+	"FAQPage":        {"mainEntity"},
+}
+
+// requiredOpenGraph lists the four properties the OpenGraph protocol requires on every page
+var requiredOpenGraph = []string{"og:title", "og:type", "og:image", "og:url"}
+
+// getStructuredDataFromHTML extracts JSON-LD, Microdata, RDFa, OpenGraph and Twitter Card metadata
+func getStructuredDataFromHTML(htmlBody string) (StructuredData, error) {
+	doc, err := html.Parse(strings.NewReader(htmlBody))
+	if err != nil {
+		return StructuredData{}, err
+	}
+	return getStructuredDataFromDoc(doc), nil
+}
+
+// getStructuredDataFromDoc is getStructuredDataFromHTML for an already parsed page
+func getStructuredDataFromDoc(root *html.Node) StructuredData {
+	doc := goquery.NewDocumentFromNode(root)
+
+	var data StructuredData
+
+	doc.Find(`script[type="application/ld+json"]`).Each(func(i int, s *goquery.Selection) {
+		items, err := parseJSONLD(s.Text())
+		if err != nil {
+			data.JSONLDErrors = append(data.JSONLDErrors, fmt.Sprintf("block %d: %v", i+1, err))
+			return
+		}
+		data.Items = append(data.Items, items...)
+	})
+
+	doc.Find("[itemscope]").Each(func(_ int, s *goquery.Selection) {
+		if _, nested := s.Attr("itemprop"); nested {
+			return
+		}
+		data.Items = append(data.Items, SchemaItem{
+			Source:     "microdata",
+			Type:       schemaTypeName(s.AttrOr("itemtype", "")),
+			Properties: ownedProperties(s, "[itemscope]", "itemprop"),
+		})
+	})
+
+	doc.Find("[typeof]").Each(func(_ int, s *goquery.Selection) {
+		if _, nested := s.Attr("property"); nested {
+			return
+		}
+		data.Items = append(data.Items, SchemaItem{
+			Source:     "rdfa",
+			Type:       schemaTypeName(s.AttrOr("typeof", "")),
+			Properties: ownedProperties(s, "[typeof]", "property"),
+		})
+	})
+
+	doc.Find("meta").Each(func(_ int, s *goquery.Selection) {
+		content, ok := s.Attr("content")
+		if !ok {
+			return
+		}
+		key := s.AttrOr("property", s.AttrOr("name", ""))
+		switch {
+		case strings.HasPrefix(key, "og:"):
+			data.OpenGraph = setFirst(data.OpenGraph, key, content)
+		case strings.HasPrefix(key, "twitter:"):
+			data.TwitterCard = setFirst(data.TwitterCard, key, content)
+		}
+	})
+
+	return data
+}
+
+// parseJSONLD parses a JSON-LD script block into its top-level typed nodes
+func parseJSONLD(raw string) ([]SchemaItem, error) {
+	var value any
+	if err := json.Unmarshal([]byte(strings.TrimSpace(raw)), &value); err != nil {
+		return nil, err
+	}
+
+	var items []SchemaItem
+	var collect func(any)
+	collect = func(v any) {
+		switch node := v.(type) {
+		case []any:
+			for _, child := range node {
+				collect(child)
+			}
+		case map[string]any:
+			if graph, ok := node["@graph"]; ok {
+				collect(graph)
+				return
+			}
+			var properties []string
+			for key := range node {
+				if !strings.HasPrefix(key, "@") {
+					properties = append(properties, key)
+				}
+			}
+			sort.Strings(properties)
+			for _, typ := range jsonLDTypes(node["@type"]) {
+				items = append(items, SchemaItem{Source: "json-ld", Type: schemaTypeName(typ), Properties: properties})
+			}
+		}
+	}
+	collect(value)
+	return items, nil
+}
+
+// jsonLDTypes returns the values of an @type field, which may be a string or a list
+func jsonLDTypes(v any) []string {
+	switch typ := v.(type) {
+	case string:
+		return []string{typ}
+	case []any:
+		var types []string
+		for _, t := range typ {
+			if s, ok := t.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// ownedProperties returns the sorted, de-duplicated property names that belong to the item itself, not to nested items
+func ownedProperties(item *goquery.Selection, scopeSelector, attr string) []string {
+	seen := make(map[string]bool)
+	var properties []string
+	item.Find("[" + attr + "]").Each(func(_ int, prop *goquery.Selection) {
+		if prop.Parent().Closest(scopeSelector).Get(0) != item.Get(0) {
+			return
+		}
+		for _, name := range strings.Fields(prop.AttrOr(attr, "")) {
+			name = schemaTypeName(name)
+			if !seen[name] {
+				seen[name] = true
+				properties = append(properties, name)
+			}
+		}
+	})
+	sort.Strings(properties)
+	return properties
+}
+
+// schemaTypeName shortens "https://schema.org/Product" or "schema:Product" to "Product"
+func schemaTypeName(typ string) string {
+	fields := strings.Fields(typ)
+	if len(fields) == 0 {
+		return ""
+	}
+	typ = fields[0]
+	if i := strings.LastIndexAny(typ, "/#:"); i >= 0 {
+		return typ[i+1:]
+	}
+	return typ
+}
+
+// setFirst records a meta value, keeping the first one when a key repeats (e.g. several og:image tags)
+func setFirst(m map[string]string, key, value string) map[string]string {
+	if m == nil {
+		m = make(map[string]string)
+	}
+	if _, exists := m[key]; !exists {
+		m[key] = value
+	}
+	return m
+}
+
+// missingProperties returns the required properties an item does not declare
+func (item SchemaItem) missingProperties() []string {
+	present := make(map[string]bool, len(item.Properties))
+	for _, p := range item.Properties {
+		present[p] = true
+	}
+	var missing []string
+	for _, p := range requiredProperties[item.Type] {
+		if !present[p] {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+// missingOpenGraph returns the required OpenGraph properties a page lacks, or nil if it has no OpenGraph tags at all
+func (data StructuredData) missingOpenGraph() []string {
+	if len(data.OpenGraph) == 0 {
+		return nil
+	}
+	var missing []string
+	for _, key := range requiredOpenGraph {
+		if data.OpenGraph[key] == "" {
+			missing = append(missing, key)
+		}
+	}
+	return missing
+}
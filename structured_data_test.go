@@ -0,0 +1,106 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetStructuredDataFromHTMLJSONLD(t *testing.T) {
+	inputBody := `<html><head>
+		<script type="application/ld+json">
+		{"@context": "https://schema.org", "@type": "BlogPosting", "headline": "Hello", "author": {"@type": "Person", "name": "Lane"}}
+		</script>
+		<script type="application/ld+json">
+		{"@context": "https://schema.org", "@graph": [{"@type": "Organization", "name": "Boot.dev"}, {"@type": ["WebSite"], "name": "Blog", "url": "https://blog.boot.dev"}]}
+		</script>
+		<script type="application/ld+json">{"@type": "Product", "name": </script>
+	</head></html>`
+
+	actual, err := getStructuredDataFromHTML(inputBody)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedItems := []SchemaItem{
+		{Source: "json-ld", Type: "BlogPosting", Properties: []string{"author", "headline"}},
+		{Source: "json-ld", Type: "Organization", Properties: []string{"name"}},
+		{Source: "json-ld", Type: "WebSite", Properties: []string{"name", "url"}},
+	}
+	if !reflect.DeepEqual(actual.Items, expectedItems) {
+		t.Errorf("expected items %+v, got %+v", expectedItems, actual.Items)
+	}
+	if len(actual.JSONLDErrors) != 1 {
+		t.Errorf("expected 1 JSON-LD error for the truncated block, got %v", actual.JSONLDErrors)
+	}
+}
+
+func TestGetStructuredDataFromHTMLMicrodataAndRDFa(t *testing.T) {
+	inputBody := `<html><body>
+		<div itemscope itemtype="https://schema.org/Product">
+			<span itemprop="name">Course</span>
+			<div itemprop="offers" itemscope itemtype="https://schema.org/Offer">
+				<span itemprop="price">29</span>
+			</div>
+		</div>
+		<div vocab="https://schema.org/" typeof="Event">
+			<span property="name">Launch</span>
+			<span property="startDate">2025-06-01</span>
+		</div>
+	</body></html>`
+
+	actual, err := getStructuredDataFromHTML(inputBody)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedItems := []SchemaItem{
+		{Source: "microdata", Type: "Product", Properties: []string{"name", "offers"}},
+		{Source: "rdfa", Type: "Event", Properties: []string{"name", "startDate"}},
+	}
+	if !reflect.DeepEqual(actual.Items, expectedItems) {
+		t.Errorf("expected items %+v, got %+v", expectedItems, actual.Items)
+	}
+}
+
+func TestGetStructuredDataFromHTMLSocialTags(t *testing.T) {
+	inputBody := `<html><head>
+		<meta property="og:title" content="Boot.dev Beat">
+		<meta property="og:image" content="https://blog.boot.dev/first.png">
+		<meta property="og:image" content="https://blog.boot.dev/second.png">
+		<meta name="twitter:card" content="summary_large_image">
+		<meta name="description" content="Not social">
+	</head></html>`
+
+	actual, err := getStructuredDataFromHTML(inputBody)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expectedOG := map[string]string{"og:title": "Boot.dev Beat", "og:image": "https://blog.boot.dev/first.png"}
+	if !reflect.DeepEqual(actual.OpenGraph, expectedOG) {
+		t.Errorf("expected OpenGraph %v, got %v", expectedOG, actual.OpenGraph)
+	}
+	expectedTwitter := map[string]string{"twitter:card": "summary_large_image"}
+	if !reflect.DeepEqual(actual.TwitterCard, expectedTwitter) {
+		t.Errorf("expected Twitter Card %v, got %v", expectedTwitter, actual.TwitterCard)
+	}
+
+	expectedMissing := []string{"og:type", "og:url"}
+	if missing := actual.missingOpenGraph(); !reflect.DeepEqual(missing, expectedMissing) {
+		t.Errorf("expected missing OpenGraph %v, got %v", expectedMissing, missing)
+	}
+}
+
+func TestSchemaItemMissingProperties(t *testing.T) {
+	item := SchemaItem{Type: "Article", Properties: []string{"headline"}}
+	expected := []string{"author", "datePublished"}
+
+	if actual := item.missingProperties(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, got %v", expected, actual)
+	}
+
+	unknown := SchemaItem{Type: "Thing"}
+	if actual := unknown.missingProperties(); actual != nil {
+		t.Errorf("expected no requirements for unknown type, got %v", actual)
+	}
+}
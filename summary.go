@@ -0,0 +1,377 @@
+package main
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// summaryTopN is how many pages the top linked and slowest lists keep
+const summaryTopN = 10
+
+// maxThroughputBuckets bounds how many intervals the crawl duration is split into
+const maxThroughputBuckets = 20
+
+// crawlSummary is the end-of-crawl overview printed to the console and written as JSON
+type crawlSummary struct {
+	StartedAt       time.Time          `json:"started_at"`
+	FinishedAt      time.Time          `json:"finished_at"`
+	DurationSeconds float64            `json:"duration_seconds"`
+	Pages           int                `json:"pages"`
+	TotalBytes      int64              `json:"total_bytes"`
+	StatusCodes     map[string]int     `json:"status_codes"`
+	ContentTypes    map[string]int     `json:"content_types"`
+	ResponseTimes   responseTimeStats  `json:"response_time_ms"`
+	Depths          []depthCount       `json:"depths"`
+	TopLinked       []linkedPage       `json:"top_linked"`
+	Slowest         []slowPage         `json:"slowest"`
+	Errors          map[string]int     `json:"errors"`
+	Throughput      []throughputBucket `json:"throughput"`
+}
+
+// responseTimeStats are percentiles of the pages' response times, in milliseconds
+type responseTimeStats struct {
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+// depthCount is how many pages were found a given number of links from the start page
+type depthCount struct {
+	Depth int `json:"depth"`
+	Pages int `json:"pages"`
+}
+
+// linkedPage is a crawled page and how many other crawled pages link to it
+type linkedPage struct {
+	URL          string `json:"url"`
+	InboundLinks int    `json:"inbound_links"`
+}
+
+// slowPage is a crawled page and how long it took to fetch
+type slowPage struct {
+	URL            string  `json:"url"`
+	ResponseMillis float64 `json:"response_ms"`
+}
+
+// throughputBucket is how many pages were fetched in one interval of the crawl
+type throughputBucket struct {
+	StartSeconds float64 `json:"start_seconds"` // since the crawl started
+	Seconds      float64 `json:"seconds"`
+	Pages        int     `json:"pages"`
+}
+
+// summarizeCrawl gathers the statistics for a crawl of baseURL that ran from startedAt to
+// finishedAt, keying link targets with the crawl's normalization policy
+func summarizeCrawl(pages pageStore, baseURL *url.URL, policy normalizePolicy, startedAt, finishedAt time.Time) (crawlSummary, error) {
+	summary := crawlSummary{
+		StartedAt:       startedAt,
+		FinishedAt:      finishedAt,
+		DurationSeconds: finishedAt.Sub(startedAt).Seconds(),
+		StatusCodes:     make(map[string]int),
+		ContentTypes:    make(map[string]int),
+		Errors:          make(map[string]int),
+		Depths:          []depthCount{},
+	}
+
+	var responseTimes []time.Duration
+	slowest := &slowestPages{}
+	var fetchTimes []time.Time
+	depths := make(map[int]int)
+	inbound := make(map[string]int)
+
+	err := pages.each(func(pageURL string, pageData PageData) error {
+		summary.Pages++
+		summary.TotalBytes += int64(pageData.Bytes)
+		summary.StatusCodes[formatStatus(pageStatus(pageData))]++
+		depths[pageData.Depth]++
+		if category := errorCategory(pageData); category != "" {
+			summary.Errors[category]++
+		} else if pageData.DeclaredContentType != "" {
+			summary.ContentTypes[pageData.DeclaredContentType]++
+		} else {
+			summary.ContentTypes["(none)"]++
+		}
+		if pageData.ResponseTime > 0 {
+			responseTimes = append(responseTimes, pageData.ResponseTime)
+			slowest.offer(slowPage{URL: displayURL(pageURL), ResponseMillis: milliseconds(pageData.ResponseTime)})
+		}
+		if !pageData.FetchedAt.IsZero() {
+			fetchTimes = append(fetchTimes, pageData.FetchedAt)
+		}
+
+		// Count each linking page once per target, and only targets the crawl could have reached
+		linked := make(map[string]bool)
+		for _, link := range pageData.OutgoingLinks {
+			parsedURL, err := url.Parse(link)
+			if err != nil || !sameHost(baseURL, parsedURL) {
+				continue
+			}
+			targetURL, err := policy.normalize(link)
+			if err != nil || targetURL == pageURL || linked[targetURL] {
+				continue
+			}
+			linked[targetURL] = true
+			inbound[targetURL]++
+		}
+		return nil
+	})
+	if err != nil {
+		return crawlSummary{}, err
+	}
+
+	summary.ResponseTimes = percentiles(responseTimes)
+
+	for depth, count := range depths {
+		summary.Depths = append(summary.Depths, depthCount{Depth: depth, Pages: count})
+	}
+	sort.Slice(summary.Depths, func(i, j int) bool { return summary.Depths[i].Depth < summary.Depths[j].Depth })
+
+	summary.Slowest = slowest.sorted()
+
+	summary.TopLinked, err = topLinked(pages, inbound)
+	if err != nil {
+		return crawlSummary{}, err
+	}
+
+	summary.Throughput = throughput(fetchTimes, startedAt, finishedAt)
+	return summary, nil
+}
+
+// slowestPages keeps the summaryTopN slowest pages seen so far in a min-heap, so the
+// fastest of them is the one replaced
+type slowestPages []slowPage
+
+func (h slowestPages) Len() int      { return len(h) }
+func (h slowestPages) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h slowestPages) Less(i, j int) bool {
+	// Of equally slow pages, the later URL goes first
+	if h[i].ResponseMillis != h[j].ResponseMillis {
+		return h[i].ResponseMillis < h[j].ResponseMillis
+	}
+	return h[i].URL > h[j].URL
+}
+func (h *slowestPages) Push(x any) { *h = append(*h, x.(slowPage)) }
+func (h *slowestPages) Pop() any {
+	old := *h
+	page := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return page
+}
+
+// offer adds a page if it is among the slowest so far
+func (h *slowestPages) offer(page slowPage) {
+	if h.Len() < summaryTopN {
+		heap.Push(h, page)
+		return
+	}
+	if fastest := (*h)[0]; page.ResponseMillis > fastest.ResponseMillis || (page.ResponseMillis == fastest.ResponseMillis && page.URL < fastest.URL) {
+		(*h)[0] = page
+		heap.Fix(h, 0)
+	}
+}
+
+// sorted returns the pages slowest first, ties in URL order
+func (h *slowestPages) sorted() []slowPage {
+	pages := make([]slowPage, h.Len())
+	for i := len(pages) - 1; i >= 0; i-- {
+		pages[i] = heap.Pop(h).(slowPage)
+	}
+	return pages
+}
+
+// errorCategory groups a failed page's error into a broad category, "" if the page was fetched
+func errorCategory(pageData PageData) string {
+	if pageData.FetchError == "" {
+		return ""
+	}
+	message := strings.ToLower(pageData.FetchError)
+	switch {
+	case pageData.StatusCode >= 500:
+		return "server error (5xx)"
+	case pageData.StatusCode >= 400:
+		return "client error (4xx)"
+	case strings.Contains(message, "unexpected content type"):
+		return "unexpected content type"
+	case strings.Contains(message, "timeout") || strings.Contains(message, "deadline exceeded"):
+		return "timeout"
+	case strings.Contains(message, "no such host"):
+		return "dns"
+	case strings.Contains(message, "connection refused") || strings.Contains(message, "connection reset") || strings.Contains(message, "eof"):
+		return "connection"
+	case strings.Contains(message, "certificate") || strings.Contains(message, "tls"):
+		return "tls"
+	case strings.Contains(message, "redirect"):
+		return "redirect"
+	default:
+		return "other"
+	}
+}
+
+// percentiles computes response time percentiles using the nearest-rank method
+func percentiles(durations []time.Duration) responseTimeStats {
+	if len(durations) == 0 {
+		return responseTimeStats{}
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	rank := func(p int) float64 {
+		i := (p*len(durations)+99)/100 - 1
+		return milliseconds(durations[max(i, 0)])
+	}
+	return responseTimeStats{
+		P50: rank(50),
+		P90: rank(90),
+		P95: rank(95),
+		P99: rank(99),
+		Max: milliseconds(durations[len(durations)-1]),
+	}
+}
+
+// topLinked returns the crawled pages with the most inbound links
+func topLinked(pages pageStore, inbound map[string]int) ([]linkedPage, error) {
+	targets := make([]string, 0, len(inbound))
+	for target := range inbound {
+		targets = append(targets, target)
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		if inbound[targets[i]] != inbound[targets[j]] {
+			return inbound[targets[i]] > inbound[targets[j]]
+		}
+		return targets[i] < targets[j]
+	})
+
+	top := []linkedPage{}
+	for _, target := range targets {
+		if len(top) == summaryTopN {
+			break
+		}
+		_, crawled, err := pages.get(target)
+		if err != nil {
+			return nil, err
+		}
+		if crawled {
+			top = append(top, linkedPage{URL: displayURL(target), InboundLinks: inbound[target]})
+		}
+	}
+	return top, nil
+}
+
+// throughput counts pages fetched in equal intervals of the crawl, at most
+// maxThroughputBuckets of them and none shorter than a second
+func throughput(fetchTimes []time.Time, startedAt, finishedAt time.Time) []throughputBucket {
+	duration := finishedAt.Sub(startedAt)
+	if duration <= 0 {
+		return []throughputBucket{}
+	}
+	size := (duration + maxThroughputBuckets - 1) / maxThroughputBuckets
+	size = max((size+time.Second-1)/time.Second, 1) * time.Second
+	count := int((duration + size - 1) / size)
+
+	buckets := make([]throughputBucket, count)
+	for i := range buckets {
+		buckets[i].StartSeconds = (time.Duration(i) * size).Seconds()
+		buckets[i].Seconds = size.Seconds()
+	}
+	for _, fetchedAt := range fetchTimes {
+		i := int(fetchedAt.Sub(startedAt) / size)
+		if i >= 0 && i < count {
+			buckets[i].Pages++
+		}
+	}
+	return buckets
+}
+
+// milliseconds converts a duration to fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// printSummary writes the summary in a human-readable form
+func printSummary(w io.Writer, summary crawlSummary) {
+	fmt.Fprintf(w, "Crawled %d pages in %.1fs (%s)\n", summary.Pages, summary.DurationSeconds, formatBytes(summary.TotalBytes))
+
+	fmt.Fprintln(w, "Status codes:")
+	printCounts(w, summary.StatusCodes)
+	fmt.Fprintln(w, "Content types:")
+	printCounts(w, summary.ContentTypes)
+	if len(summary.Errors) > 0 {
+		fmt.Fprintln(w, "Errors:")
+		printCounts(w, summary.Errors)
+	}
+
+	rt := summary.ResponseTimes
+	fmt.Fprintf(w, "Response times: p50 %.0fms, p90 %.0fms, p95 %.0fms, p99 %.0fms, max %.0fms\n", rt.P50, rt.P90, rt.P95, rt.P99, rt.Max)
+
+	fmt.Fprintln(w, "Pages per depth:")
+	for _, d := range summary.Depths {
+		fmt.Fprintf(w, "  %d: %d\n", d.Depth, d.Pages)
+	}
+
+	if len(summary.TopLinked) > 0 {
+		fmt.Fprintln(w, "Most linked pages:")
+		for _, page := range summary.TopLinked {
+			fmt.Fprintf(w, "  %d  %s\n", page.InboundLinks, page.URL)
+		}
+	}
+	if len(summary.Slowest) > 0 {
+		fmt.Fprintln(w, "Slowest pages:")
+		for _, page := range summary.Slowest {
+			fmt.Fprintf(w, "  %.0fms  %s\n", page.ResponseMillis, page.URL)
+		}
+	}
+
+	if len(summary.Throughput) > 1 {
+		fmt.Fprintln(w, "Throughput:")
+		for _, bucket := range summary.Throughput {
+			fmt.Fprintf(w, "  %4.0fs  %d pages (%.1f/s)\n", bucket.StartSeconds, bucket.Pages, float64(bucket.Pages)/bucket.Seconds)
+		}
+	}
+}
+
+// printCounts writes counts largest first, ties in name order
+func printCounts(w io.Writer, counts map[string]int) {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s: %d\n", name, counts[name])
+	}
+}
+
+// formatBytes formats a byte count with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// writeSummaryJSON writes the summary as an indented JSON file
+func writeSummaryJSON(summary crawlSummary, filename string) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0o644)
+}
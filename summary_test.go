@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+var summaryTestURL, _ = url.Parse("https://example.com")
+
+func TestSummarizeCrawl(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	pages := map[string]PageData{
+		"example.com": {
+			URL: "https://example.com", StatusCode: 200, DeclaredContentType: "text/html", Bytes: 1000,
+			OutgoingLinks: []string{"https://example.com/a", "https://example.com/a", "https://example.com/missing"},
+			FetchedAt:     start, ResponseTime: 100 * time.Millisecond,
+		},
+		"example.com/a": {
+			URL: "https://example.com/a", StatusCode: 200, DeclaredContentType: "text/html", Bytes: 2000, Depth: 1,
+			OutgoingLinks: []string{"https://example.com/", "https://example.com/b"},
+			FetchedAt:     start.Add(time.Second), ResponseTime: 300 * time.Millisecond,
+		},
+		"example.com/b": {
+			URL: "https://example.com/b", StatusCode: 404, FetchError: "error status code: 404", Depth: 2,
+			FetchedAt: start.Add(2 * time.Second), ResponseTime: 200 * time.Millisecond,
+		},
+		"example.com/c": {
+			URL: "https://example.com/c", FetchError: "dial tcp: lookup example.com: no such host", Depth: 2,
+			FetchedAt: start.Add(2 * time.Second), ResponseTime: 50 * time.Millisecond,
+		},
+	}
+
+	summary, err := summarizeCrawl(newMemoryStore(pages), summaryTestURL, normalizePolicy{}, start, start.Add(3*time.Second))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if summary.Pages != 4 || summary.TotalBytes != 3000 {
+		t.Errorf("pages = %d, bytes = %d; want 4 and 3000", summary.Pages, summary.TotalBytes)
+	}
+	if want := map[string]int{"200": 2, "404": 1, "no response": 1}; !reflect.DeepEqual(summary.StatusCodes, want) {
+		t.Errorf("status codes = %v, want %v", summary.StatusCodes, want)
+	}
+	if want := map[string]int{"text/html": 2}; !reflect.DeepEqual(summary.ContentTypes, want) {
+		t.Errorf("content types = %v, want %v", summary.ContentTypes, want)
+	}
+	if want := map[string]int{"client error (4xx)": 1, "dns": 1}; !reflect.DeepEqual(summary.Errors, want) {
+		t.Errorf("errors = %v, want %v", summary.Errors, want)
+	}
+	if want := (responseTimeStats{P50: 100, P90: 300, P95: 300, P99: 300, Max: 300}); summary.ResponseTimes != want {
+		t.Errorf("response times = %+v, want %+v", summary.ResponseTimes, want)
+	}
+	if want := []depthCount{{0, 1}, {1, 1}, {2, 2}}; !reflect.DeepEqual(summary.Depths, want) {
+		t.Errorf("depths = %v, want %v", summary.Depths, want)
+	}
+
+	// Repeated links count once and links to uncrawled pages are left out
+	wantLinked := []linkedPage{{"example.com", 1}, {"example.com/a", 1}, {"example.com/b", 1}}
+	if !reflect.DeepEqual(summary.TopLinked, wantLinked) {
+		t.Errorf("top linked = %v, want %v", summary.TopLinked, wantLinked)
+	}
+	if len(summary.Slowest) != 4 || summary.Slowest[0].URL != "example.com/a" {
+		t.Errorf("slowest = %v, want example.com/a first", summary.Slowest)
+	}
+
+	wantThroughput := []throughputBucket{{0, 1, 1}, {1, 1, 1}, {2, 1, 2}}
+	if !reflect.DeepEqual(summary.Throughput, wantThroughput) {
+		t.Errorf("throughput = %v, want %v", summary.Throughput, wantThroughput)
+	}
+}
+
+func TestSummarizeCrawlInboundLinks(t *testing.T) {
+	// The crawl kept queries, so /list and /list?page=2 are different pages
+	pages := map[string]PageData{
+		"example.com": {URL: "https://example.com", StatusCode: 200, OutgoingLinks: []string{
+			"https://example.com/list?page=2", "https://example.com/list?page=2#top", "https://other.example/list", "http://example.com/list",
+		}},
+		"example.com/list":        {URL: "https://example.com/list", StatusCode: 200, OutgoingLinks: []string{"https://example.com/list?page=2"}},
+		"example.com/list?page=2": {URL: "https://example.com/list?page=2", StatusCode: 200},
+	}
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	summary, err := summarizeCrawl(newMemoryStore(pages), summaryTestURL, normalizePolicy{Query: queryKeepAll}, start, start.Add(time.Second))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []linkedPage{{"example.com/list?page=2", 2}, {"example.com/list", 1}}
+	if !reflect.DeepEqual(summary.TopLinked, want) {
+		t.Errorf("top linked = %v, want %v", summary.TopLinked, want)
+	}
+}
+
+func TestSlowestPages(t *testing.T) {
+	// More pages than the list keeps, with ties across the cutoff
+	var all []slowPage
+	slowest := &slowestPages{}
+	for i := 0; i < 3*summaryTopN; i++ {
+		page := slowPage{URL: fmt.Sprintf("example.com/%02d", i), ResponseMillis: float64((i * 7) % 13)}
+		all = append(all, page)
+		slowest.offer(page)
+	}
+
+	sort.SliceStable(all, func(i, j int) bool { return all[i].ResponseMillis > all[j].ResponseMillis })
+	if got := slowest.sorted(); !reflect.DeepEqual(got, all[:summaryTopN]) {
+		t.Errorf("slowest = %v, want %v", got, all[:summaryTopN])
+	}
+}
+
+func TestErrorCategory(t *testing.T) {
+	tests := []struct {
+		name     string
+		pageData PageData
+		expected string
+	}{
+		{name: "success", pageData: PageData{StatusCode: 200}, expected: ""},
+		{name: "server error", pageData: PageData{StatusCode: 503, FetchError: "error status code: 503"}, expected: "server error (5xx)"},
+		{name: "client error", pageData: PageData{StatusCode: 410, FetchError: "error status code: 410"}, expected: "client error (4xx)"},
+		{name: "content type", pageData: PageData{StatusCode: 200, FetchError: "unexpected content type: image/png"}, expected: "unexpected content type"},
+		{name: "timeout", pageData: PageData{FetchError: "Get \"https://example.com\": context deadline exceeded (Client.Timeout exceeded while awaiting headers)"}, expected: "timeout"},
+		{name: "dns", pageData: PageData{FetchError: "dial tcp: lookup nope.example: no such host"}, expected: "dns"},
+		{name: "refused", pageData: PageData{FetchError: "dial tcp 127.0.0.1:1: connect: connection refused"}, expected: "connection"},
+		{name: "tls", pageData: PageData{FetchError: "tls: failed to verify certificate"}, expected: "tls"},
+		{name: "redirects", pageData: PageData{FetchError: "stopped after 10 redirects"}, expected: "redirect"},
+		{name: "other", pageData: PageData{FetchError: "something odd"}, expected: "other"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := errorCategory(tc.pageData); got != tc.expected {
+				t.Errorf("errorCategory = %q, want %q", got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestThroughputBucketCount(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		duration time.Duration
+		buckets  int
+		size     float64
+	}{
+		{name: "no time", duration: 0, buckets: 0},
+		{name: "under a second", duration: 300 * time.Millisecond, buckets: 1, size: 1},
+		{name: "short crawl uses seconds", duration: 5500 * time.Millisecond, buckets: 6, size: 1},
+		{name: "long crawl is capped", duration: time.Hour, buckets: 20, size: 180},
+		{name: "sizes round up", duration: 30 * time.Second, buckets: 15, size: 2},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			buckets := throughput(nil, start, start.Add(tc.duration))
+			if len(buckets) != tc.buckets {
+				t.Fatalf("got %d buckets, want %d", len(buckets), tc.buckets)
+			}
+			if tc.buckets > 0 && buckets[0].Seconds != tc.size {
+				t.Errorf("bucket size = %vs, want %vs", buckets[0].Seconds, tc.size)
+			}
+		})
+	}
+}
+
+func TestPrintAndWriteSummary(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	pages := map[string]PageData{
+		"example.com": {URL: "https://example.com", StatusCode: 200, DeclaredContentType: "text/html", Bytes: 2048, FetchedAt: start, ResponseTime: 120 * time.Millisecond},
+	}
+	summary, err := summarizeCrawl(newMemoryStore(pages), summaryTestURL, normalizePolicy{}, start, start.Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	printSummary(&out, summary)
+	for _, want := range []string{"Crawled 1 pages in 1.0s (2.0 KiB)", "200: 1", "text/html: 1", "p50 120ms", "120ms  example.com"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("summary output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	filename := filepath.Join(t.TempDir(), "summary.json")
+	if err := writeSummaryJSON(summary, filename); err != nil {
+		t.Fatalf("writeSummaryJSON: %v", err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("summary is not JSON: %v", err)
+	}
+	for _, key := range []string{"status_codes", "content_types", "response_time_ms", "depths", "top_linked", "slowest", "errors", "throughput"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("summary JSON missing %q", key)
+		}
+	}
+}
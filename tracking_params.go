@@ -0,0 +1,289 @@
+package main
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// defaultTrackingParams are analytics and session parameters that never change page content.
+// Entries ending in "*" match any parameter with that prefix.
+var defaultTrackingParams = []string{
+	"utm_*", "gclid", "gclsrc", "dclid", "fbclid", "msclkid", "yclid", "igshid",
+	"mc_cid", "mc_eid", "_ga", "_gl", "ref_src",
+	"jsessionid", "phpsessid", "aspsessionid*", "sid", "sessionid", "session_id",
+}
+
+// matchesParam reports whether a parameter name is in the list, ignoring case
+func matchesParam(name string, list []string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range list {
+		pattern = strings.ToLower(pattern)
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// stripPathParams removes matrix parameters like ";jsessionid=ABC123" from a path
+func stripPathParams(path string, list []string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		parts := strings.Split(segment, ";")
+		kept := parts[:1]
+		for _, param := range parts[1:] {
+			name, _, _ := strings.Cut(param, "=")
+			if !matchesParam(name, list) {
+				kept = append(kept, param)
+			}
+		}
+		segments[i] = strings.Join(kept, ";")
+	}
+	return strings.Join(segments, "/")
+}
+
+// paramLearner detects query parameters whose values vary across pages with identical content,
+// such as session IDs the tracking list doesn't know about. What it learns applies only to the
+// host and path it was observed on, since the same name can matter elsewhere on a site.
+type paramLearner struct {
+	mu      sync.Mutex
+	seen    map[string]map[string]string // URL without the param, plus the param name -> param value -> content hash
+	samples map[string]int               // host and path, plus the param name -> values kept in seen
+	ignored map[string]ignoredParam      // host and path, plus the param name
+}
+
+// maxParamSamples is how many values of one parameter on one host and path the learner keeps
+// as evidence. Later values are still compared against them but not kept, so a parameter that
+// does change content, like a product ID, can't grow the learner without limit.
+const maxParamSamples = 100
+
+// ignoredParam records why the learner decided a parameter doesn't affect content
+type ignoredParam struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"` // host and path the parameter is ignored on
+	FirstURL string `json:"first_url"`
+	OtherURL string `json:"other_url"`
+}
+
+// newParamLearner creates a paramLearner with nothing learned yet
+func newParamLearner() *paramLearner {
+	return &paramLearner{
+		seen:    make(map[string]map[string]string),
+		samples: make(map[string]int),
+		ignored: make(map[string]ignoredParam),
+	}
+}
+
+// learnerScope returns the host and path a learned parameter applies to
+func learnerScope(parsedURL *url.URL) string {
+	return parsedURL.Host + parsedURL.Path
+}
+
+// observe records the content hash of a fetched URL. When two URLs that differ only in one
+// parameter's value have the same content, that parameter is ignored on the URL's host and
+// path from then on. Pages without content ("" hash) are no evidence and are skipped.
+func (l *paramLearner) observe(rawURL, contentHash string) {
+	if contentHash == "" {
+		return
+	}
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil || parsedURL.RawQuery == "" {
+		return
+	}
+	scope := learnerScope(parsedURL)
+	values := parsedURL.Query()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for name := range values {
+		if _, done := l.ignored[scope+"#"+name]; done {
+			continue
+		}
+
+		others := url.Values{}
+		for key, vals := range values {
+			if key != name {
+				others[key] = vals
+			}
+		}
+		key := scope + "?" + others.Encode() + "#" + name
+		value := strings.Join(values[name], ",")
+
+		byValue := l.seen[key]
+		for otherValue, otherHash := range byValue {
+			if otherValue != value && otherHash == contentHash {
+				otherURL := *parsedURL
+				otherValues := parsedURL.Query()
+				otherValues.Set(name, otherValue)
+				otherURL.RawQuery = otherValues.Encode()
+				l.ignored[scope+"#"+name] = ignoredParam{Name: name, Path: scope, FirstURL: otherURL.String(), OtherURL: rawURL}
+				break
+			}
+		}
+		if _, kept := byValue[value]; !kept {
+			if l.samples[scope+"#"+name] >= maxParamSamples {
+				continue
+			}
+			l.samples[scope+"#"+name]++
+		}
+		if byValue == nil {
+			byValue = make(map[string]string)
+			l.seen[key] = byValue
+		}
+		byValue[value] = contentHash
+	}
+}
+
+// isIgnored reports whether a parameter has been learned to not affect content on a host and path
+func (l *paramLearner) isIgnored(scope, name string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.ignored[scope+"#"+name]
+	return ok
+}
+
+// ignoredParams returns the learned parameters sorted by path and name
+func (l *paramLearner) ignoredParams() []ignoredParam {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	params := make([]ignoredParam, 0, len(l.ignored))
+	for _, p := range l.ignored {
+		params = append(params, p)
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i].Path != params[j].Path {
+			return params[i].Path < params[j].Path
+		}
+		return params[i].Name < params[j].Name
+	})
+	return params
+}
+
+// learnerState is what a paramLearner has seen and learned, for saving in a checkpoint
+type learnerState struct {
+	Seen    map[string]map[string]string `json:"seen,omitempty"`    // as in paramLearner.seen
+	Samples map[string]int               `json:"samples,omitempty"` // as in paramLearner.samples
+	Ignored []ignoredParam               `json:"ignored,omitempty"`
+}
+
+// state returns a copy of the learner's evidence and learned parameters
+func (l *paramLearner) state() *learnerState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	seen := make(map[string]map[string]string, len(l.seen))
+	for key, byValue := range l.seen {
+		seen[key] = make(map[string]string, len(byValue))
+		for value, hash := range byValue {
+			seen[key][value] = hash
+		}
+	}
+	samples := make(map[string]int, len(l.samples))
+	for key, count := range l.samples {
+		samples[key] = count
+	}
+	ignored := make([]ignoredParam, 0, len(l.ignored))
+	for _, p := range l.ignored {
+		ignored = append(ignored, p)
+	}
+	return &learnerState{Seen: seen, Samples: samples, Ignored: ignored}
+}
+
+// restore adds the evidence and learned parameters of a saved state to the learner
+func (l *paramLearner) restore(state learnerState) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key, byValue := range state.Seen {
+		if l.seen[key] == nil {
+			l.seen[key] = make(map[string]string, len(byValue))
+		}
+		for value, hash := range byValue {
+			l.seen[key][value] = hash
+		}
+	}
+	for key, count := range state.Samples {
+		l.samples[key] += count
+	}
+	for _, p := range state.Ignored {
+		l.ignored[p.Path+"#"+p.Name] = p
+	}
+}
+
+// rekeyLearnedPages moves pages stored before a parameter in their URL was learned to be
+// ignorable to the key the crawl's normalizer now gives them. Variants that collapse onto
+// a page already stored are dropped, and duplicate references follow the moved pages.
+func (cfg *config) rekeyLearnedPages() error {
+	if cfg.normalizer.Learner == nil {
+		return nil
+	}
+	unlearned := cfg.normalizer
+	unlearned.Learner = nil
+
+	type move struct {
+		from, to string
+		pageData PageData
+	}
+	var moves []move
+	err := cfg.pages.each(func(pageURL string, pageData PageData) error {
+		if pageData.URL == "" {
+			return nil
+		}
+		if key, err := unlearned.normalize(pageData.URL); err != nil || key != pageURL {
+			return nil
+		}
+		if key, err := cfg.normalizer.normalize(pageData.URL); err == nil && key != pageURL {
+			moves = append(moves, move{from: pageURL, to: key, pageData: pageData})
+		}
+		return nil
+	})
+	if err != nil || len(moves) == 0 {
+		return err
+	}
+
+	renamed := make(map[string]string, len(moves))
+	for _, m := range moves {
+		existing, ok, err := cfg.pages.get(m.to)
+		if err != nil {
+			return err
+		}
+		if !ok || existing.URL == "" {
+			if err := cfg.pages.put(m.to, m.pageData); err != nil {
+				return err
+			}
+		}
+		if err := cfg.pages.remove(m.from); err != nil {
+			return err
+		}
+		renamed[m.from] = m.to
+	}
+
+	updated := make(map[string]PageData)
+	err = cfg.pages.each(func(pageURL string, pageData PageData) error {
+		if to, ok := renamed[pageData.DuplicateOf]; ok {
+			pageData.DuplicateOf = to
+			if to == pageURL {
+				pageData.DuplicateOf = ""
+			}
+			updated[pageURL] = pageData
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for pageURL, pageData := range updated {
+		if err := cfg.pages.put(pageURL, pageData); err != nil {
+			return err
+		}
+	}
+	return nil
+}
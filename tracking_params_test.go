@@ -0,0 +1,206 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestNormalizePolicyStripsTrackingParams(t *testing.T) {
+	policy := normalizePolicy{Query: queryKeepAll, StripParams: defaultTrackingParams}
+
+	tests := []struct {
+		name     string
+		inputURL string
+		expected string
+	}{
+		{
+			name:     "utm prefix",
+			inputURL: "https://blog.boot.dev/post?utm_source=x&utm_medium=email&page=2",
+			expected: "blog.boot.dev/post?page=2",
+		},
+		{
+			name:     "click ids",
+			inputURL: "https://blog.boot.dev/post?gclid=abc&fbclid=def",
+			expected: "blog.boot.dev/post",
+		},
+		{
+			name:     "session params are case-insensitive",
+			inputURL: "https://blog.boot.dev/post?PHPSESSID=123&sid=456",
+			expected: "blog.boot.dev/post",
+		},
+		{
+			name:     "jsessionid path parameter",
+			inputURL: "https://blog.boot.dev/cart;jsessionid=A1B2C3?item=7",
+			expected: "blog.boot.dev/cart?item=7",
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := policy.normalize(tc.inputURL)
+			if err != nil {
+				t.Errorf("Test %v - '%s' FAIL: unexpected error: %v", i, tc.name, err)
+				return
+			}
+			if actual != tc.expected {
+				t.Errorf("Test %v - %s FAIL: expected URL: %v, actual: %v", i, tc.name, tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParamLearnerIgnoresParamsThatDontChangeContent(t *testing.T) {
+	learner := newParamLearner()
+	learner.observe("https://shop.example.com/list?page=1&visit=aaa", "hash-page-1")
+	learner.observe("https://shop.example.com/list?page=2&visit=aaa", "hash-page-2")
+	learner.observe("https://shop.example.com/list?page=1&visit=bbb", "hash-page-1")
+
+	if !learner.isIgnored("shop.example.com/list", "visit") {
+		t.Error("expected 'visit' to be ignored after identical content with different values")
+	}
+	if learner.isIgnored("shop.example.com/list", "page") {
+		t.Error("expected 'page' to be kept since its values change the content")
+	}
+
+	ignored := learner.ignoredParams()
+	if len(ignored) != 1 || ignored[0].Name != "visit" || ignored[0].Path != "shop.example.com/list" {
+		t.Fatalf("expected only 'visit' to be reported, got %+v", ignored)
+	}
+	if ignored[0].OtherURL != "https://shop.example.com/list?page=1&visit=bbb" {
+		t.Errorf("unexpected evidence URL: %s", ignored[0].OtherURL)
+	}
+
+	policy := normalizePolicy{Query: queryKeepAll, Learner: learner}
+	actual, _ := policy.normalize("https://shop.example.com/list?visit=ccc&page=3")
+	if actual != "shop.example.com/list?page=3" {
+		t.Errorf("expected learned parameter to be dropped, got %s", actual)
+	}
+}
+
+func TestParamLearnerScopeAndEvidence(t *testing.T) {
+	learner := newParamLearner()
+	// Two empty pages are no evidence that "id" is irrelevant
+	learner.observe("https://shop.example.com/x?id=1", "")
+	learner.observe("https://shop.example.com/x?id=2", "")
+	if learner.isIgnored("shop.example.com/x", "id") {
+		t.Error("expected pages without content to teach nothing")
+	}
+
+	// Learning "id" on one path leaves it significant everywhere else
+	learner.observe("https://shop.example.com/promo?id=1", "hash-promo")
+	learner.observe("https://shop.example.com/promo?id=2", "hash-promo")
+	if !learner.isIgnored("shop.example.com/promo", "id") {
+		t.Error("expected 'id' to be ignored on /promo")
+	}
+	policy := normalizePolicy{Query: queryKeepAll, Learner: learner}
+	for _, tc := range []struct{ input, expected string }{
+		{"https://shop.example.com/promo?id=3", "shop.example.com/promo"},
+		{"https://shop.example.com/product?id=1", "shop.example.com/product?id=1"},
+		{"https://other.example.com/promo?id=1", "other.example.com/promo?id=1"},
+	} {
+		if actual, _ := policy.normalize(tc.input); actual != tc.expected {
+			t.Errorf("normalize(%s) = %s, want %s", tc.input, actual, tc.expected)
+		}
+	}
+}
+
+func TestParamLearnerCapsSamples(t *testing.T) {
+	learner := newParamLearner()
+	for i := 0; i < 3*maxParamSamples; i++ {
+		learner.observe(fmt.Sprintf("https://shop.example.com/product?id=%d", i), fmt.Sprintf("hash-%d", i))
+	}
+
+	kept := 0
+	for _, byValue := range learner.state().Seen {
+		kept += len(byValue)
+	}
+	if kept != maxParamSamples {
+		t.Errorf("expected %d samples kept, got %d", maxParamSamples, kept)
+	}
+	if learner.isIgnored("shop.example.com/product", "id") {
+		t.Fatal("expected 'id' to be kept since its values change the content")
+	}
+
+	// Values past the cap are still compared against the kept ones
+	learner.observe("https://shop.example.com/product?id=copy-of-0", "hash-0")
+	if !learner.isIgnored("shop.example.com/product", "id") {
+		t.Error("expected 'id' to be ignored once a new value matches a kept one")
+	}
+}
+
+func TestRekeyLearnedPages(t *testing.T) {
+	learner := newParamLearner()
+	learner.observe("https://example.com/?s=1", "hash-home")
+	learner.observe("https://example.com/?s=2", "hash-home")
+
+	store := newMemoryStore(map[string]PageData{
+		"example.com?s=1":         {URL: "https://example.com/?s=1"},
+		"example.com?s=2":         {URL: "https://example.com/?s=2", DuplicateOf: "example.com?s=1"},
+		"example.com/about":       {URL: "https://example.com/about", DuplicateOf: "example.com?s=1"},
+		"example.com/product?s=1": {URL: "https://example.com/product?s=1"},
+	})
+	cfg := &config{pages: store, normalizer: normalizePolicy{Query: queryKeepAll, Learner: learner}}
+	if err := cfg.rekeyLearnedPages(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]PageData{
+		"example.com":             {URL: "https://example.com/?s=1"},
+		"example.com/about":       {URL: "https://example.com/about", DuplicateOf: "example.com"},
+		"example.com/product?s=1": {URL: "https://example.com/product?s=1"},
+	}
+	if !reflect.DeepEqual(store.pages, want) {
+		t.Errorf("pages = %v, want %v", store.pages, want)
+	}
+}
+
+func TestCrawlLearnsSessionParams(t *testing.T) {
+	// Every page links to a session-tagged copy of itself with a new token,
+	// which would never end without learning that "token" is irrelevant
+	var mu sync.Mutex
+	counter := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		counter++
+		token := fmt.Sprintf("%x", sha256.Sum256([]byte(fmt.Sprint(counter))))[:12]
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><h1>Home</h1><p>Same content.</p><a href="/?token=` + token + `">again</a></body></html>`))
+	}))
+	defer server.Close()
+
+	baseURL, _ := url.Parse(server.URL)
+	store := newMemoryStore(nil)
+	cfg := &config{
+		pages:              store,
+		baseURL:            baseURL,
+		mu:                 &sync.Mutex{},
+		concurrencyControl: make(chan struct{}, 1),
+		wg:                 &sync.WaitGroup{},
+		maxPages:           20,
+		normalizer:         normalizePolicy{Query: queryKeepAll, Learner: newParamLearner()},
+	}
+
+	cfg.enqueue(server.URL+"/?token=first", 0)
+	if err := cfg.crawl(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two token variants are fetched before the learner has evidence, then every
+	// later link collapses to the token-less key, and the variants are moved onto it
+	if len(store.pages) != 1 {
+		t.Errorf("expected the token variants to settle on one page, got %v", store.pages)
+	}
+	if _, ok := store.pages[baseURL.Host]; !ok {
+		t.Errorf("expected the page under the token-less key, got %v", store.pages)
+	}
+	if !cfg.normalizer.Learner.isIgnored(baseURL.Host+"/", "token") {
+		t.Error("expected 'token' to be learned as ignorable")
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// maxRecordedTraps bounds how many suspected trap URLs are kept for the report
+const maxRecordedTraps = 10000
+
+// trapDetector flags URLs that look like crawler traps: endless calendars, faceted
+// search and relative-link loops. A zero limit disables that heuristic.
+type trapDetector struct {
+	MaxPathDepth      int // path segments
+	MaxURLLength      int // characters
+	MaxSegmentRepeats int // times the same segment may appear in one path
+	MaxQueryVariants  int // distinct normalized URLs with a query per path
+	MaxPagesPerDir    int // distinct normalized URLs per directory
+
+	mu       sync.Mutex
+	variants map[string]int    // host+path -> accepted URLs with a query
+	dirPages map[string]int    // host+directory -> accepted URLs
+	suspects map[string]string // URL -> reason
+}
+
+// suspectedTrap is a URL the crawler refused to enqueue
+type suspectedTrap struct {
+	URL    string
+	Reason string
+}
+
+// newTrapDetector creates a trapDetector with limits generous enough for normal sites.
+// The per-directory limit is off, since flat sites legitimately keep every page in one directory.
+func newTrapDetector() *trapDetector {
+	return &trapDetector{
+		MaxPathDepth:      15,
+		MaxURLLength:      2000,
+		MaxSegmentRepeats: 2,
+		MaxQueryVariants:  100,
+		variants:          make(map[string]int),
+		dirPages:          make(map[string]int),
+		suspects:          make(map[string]string),
+	}
+}
+
+// check returns why a URL looks like a trap, or "" if it is safe to enqueue. Safe URLs
+// count towards the per-path and per-directory limits, so callers check each normalized
+// URL only the first time it is found; variants the crawl dedupes anyway, like tracking
+// parameters, then count once. Only counts are kept, not the URLs.
+func (d *trapDetector) check(u *url.URL, normalizedURL string) string {
+	// Fragments point into the same page, so they shouldn't count as new URLs
+	withoutFragment := *u
+	withoutFragment.Fragment, withoutFragment.RawFragment = "", ""
+	rawURL := withoutFragment.String()
+	reason := d.staticReason(u, rawURL)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if reason == "" {
+		reason = d.countReason(u, normalizedURL)
+	}
+	if reason != "" && len(d.suspects) < maxRecordedTraps {
+		d.suspects[rawURL] = reason
+	}
+	return reason
+}
+
+// staticReason applies the heuristics that only need the URL itself
+func (d *trapDetector) staticReason(u *url.URL, rawURL string) string {
+	if d.MaxURLLength > 0 && len(rawURL) > d.MaxURLLength {
+		return fmt.Sprintf("URL length %d exceeds %d", len(rawURL), d.MaxURLLength)
+	}
+
+	var segments []string
+	for _, segment := range strings.Split(u.Path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	if d.MaxPathDepth > 0 && len(segments) > d.MaxPathDepth {
+		return fmt.Sprintf("path depth %d exceeds %d", len(segments), d.MaxPathDepth)
+	}
+
+	if d.MaxSegmentRepeats > 0 {
+		counts := make(map[string]int)
+		for _, segment := range segments {
+			counts[segment]++
+			if counts[segment] > d.MaxSegmentRepeats {
+				return fmt.Sprintf("path segment %q repeated %d times", segment, counts[segment])
+			}
+		}
+	}
+
+	return ""
+}
+
+// countReason applies the heuristics that depend on URLs already accepted; d.mu must be held
+func (d *trapDetector) countReason(u *url.URL, normalizedURL string) string {
+	path, dir, hasQuery := trapCountKeys(u, normalizedURL)
+
+	if d.MaxQueryVariants > 0 && hasQuery && d.variants[path] >= d.MaxQueryVariants {
+		return fmt.Sprintf("more than %d query variants of %s", d.MaxQueryVariants, path)
+	}
+	if d.MaxPagesPerDir > 0 && d.dirPages[dir] >= d.MaxPagesPerDir {
+		return fmt.Sprintf("more than %d pages in directory %s", d.MaxPagesPerDir, dir)
+	}
+
+	if hasQuery {
+		d.variants[path]++
+	}
+	d.dirPages[dir]++
+	return ""
+}
+
+// release takes back the counts of a URL check accepted, for when it turns out to have been found before
+func (d *trapDetector) release(u *url.URL, normalizedURL string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	path, dir, hasQuery := trapCountKeys(u, normalizedURL)
+	if hasQuery {
+		d.variants[path]--
+	}
+	d.dirPages[dir]--
+}
+
+// trapCountKeys returns the path and directory a URL counts towards, and whether it
+// counts as a query variant: only if a query survives normalization
+func trapCountKeys(u *url.URL, normalizedURL string) (path, dir string, hasQuery bool) {
+	path = u.Host + u.Path
+	dir = path[:strings.LastIndex(path, "/")+1]
+	return path, dir, strings.Contains(normalizedURL, "?")
+}
+
+// trapState is what a trapDetector has counted, for saving in a checkpoint
+type trapState struct {
+	Variants map[string]int    `json:"variants,omitempty"`  // host+path -> accepted URLs with a query
+	DirPages map[string]int    `json:"dir_pages,omitempty"` // host+directory -> accepted URLs
+	Suspects map[string]string `json:"suspects,omitempty"`  // URL -> reason
+}
+
+// state returns a copy of the counts so far
+func (d *trapDetector) state() *trapState {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return &trapState{Variants: maps.Clone(d.variants), DirPages: maps.Clone(d.dirPages), Suspects: maps.Clone(d.suspects)}
+}
+
+// restore adds the counts of a saved state to the detector
+func (d *trapDetector) restore(state trapState) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for path, count := range state.Variants {
+		d.variants[path] += count
+	}
+	for dir, count := range state.DirPages {
+		d.dirPages[dir] += count
+	}
+	for rawURL, reason := range state.Suspects {
+		d.suspects[rawURL] = reason
+	}
+}
+
+// suspectedTraps returns the URLs that were not enqueued, sorted by URL
+func (d *trapDetector) suspectedTraps() []suspectedTrap {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	traps := make([]suspectedTrap, 0, len(d.suspects))
+	for rawURL, reason := range d.suspects {
+		traps = append(traps, suspectedTrap{URL: rawURL, Reason: reason})
+	}
+	sort.Slice(traps, func(i, j int) bool { return traps[i].URL < traps[j].URL })
+	return traps
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTrapDetectorStaticHeuristics(t *testing.T) {
+	tests := []struct {
+		name     string
+		inputURL string
+		isTrap   bool
+	}{
+		{
+			name:     "normal page",
+			inputURL: "https://blog.boot.dev/news/2025/06/beat",
+			isTrap:   false,
+		},
+		{
+			name:     "repeated relative-link segments",
+			inputURL: "https://blog.boot.dev/a/b/a/b/a/b",
+			isTrap:   true,
+		},
+		{
+			name:     "excessive path depth",
+			inputURL: "https://blog.boot.dev/" + strings.Repeat("x/", 8) + strings.Repeat("y/", 8),
+			isTrap:   true,
+		},
+		{
+			name:     "URL too long",
+			inputURL: "https://blog.boot.dev/search?q=" + strings.Repeat("z", 2100),
+			isTrap:   true,
+		},
+	}
+
+	for i, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d := newTrapDetector()
+			u, _ := url.Parse(tc.inputURL)
+			reason := d.check(u, tc.inputURL)
+			if (reason != "") != tc.isTrap {
+				t.Errorf("Test %v - %s FAIL: expected trap: %v, got reason: %q", i, tc.name, tc.isTrap, reason)
+			}
+		})
+	}
+}
+
+// trapConfig returns a crawl of example.com using the detector and policy, with nothing queued yet
+func trapConfig(d *trapDetector, policy normalizePolicy) *config {
+	baseURL, _ := url.Parse("https://example.com")
+	return &config{pages: newMemoryStore(nil), baseURL: baseURL, mu: &sync.Mutex{}, traps: d, normalizer: policy}
+}
+
+// linkRefused queues a link and reports whether it was refused as a suspected trap
+func linkRefused(cfg *config, link string) bool {
+	cfg.enqueueLinks([]string{link}, 1)
+	for _, trap := range cfg.traps.suspectedTraps() {
+		if trap.URL == link {
+			return true
+		}
+	}
+	return false
+}
+
+func TestTrapDetectorQueryVariants(t *testing.T) {
+	d := newTrapDetector()
+	d.MaxQueryVariants = 3
+	cfg := trapConfig(d, normalizePolicy{Query: queryKeepAll, StripParams: defaultTrackingParams})
+
+	for day := 1; day <= 3; day++ {
+		if linkRefused(cfg, fmt.Sprintf("https://example.com/calendar?day=%d", day)) {
+			t.Fatalf("expected day %d to be allowed", day)
+		}
+	}
+
+	// Seeing an accepted variant again is fine, even with tracking parameters added
+	for _, rawURL := range []string{"https://example.com/calendar?day=2", "https://example.com/calendar?day=2&utm_source=a", "https://example.com/calendar?utm_source=b&day=3"} {
+		if linkRefused(cfg, rawURL) {
+			t.Errorf("expected repeat of an accepted variant %s to be allowed", rawURL)
+		}
+	}
+
+	if !linkRefused(cfg, "https://example.com/calendar?day=4") {
+		t.Error("expected fourth query variant to be flagged")
+	}
+
+	traps := d.suspectedTraps()
+	if len(traps) != 1 || traps[0].URL != "https://example.com/calendar?day=4" {
+		t.Errorf("expected day=4 in suspected traps, got %+v", traps)
+	}
+	if cfg.pages.queueLen() != 3 {
+		t.Errorf("expected the 3 accepted days to be queued, got %d", cfg.pages.queueLen())
+	}
+}
+
+func TestTrapDetectorPagesPerDirOffByDefault(t *testing.T) {
+	cfg := trapConfig(newTrapDetector(), normalizePolicy{})
+	for i := 0; i < 2000; i++ {
+		if link := fmt.Sprintf("https://example.com/post-%d", i); linkRefused(cfg, link) {
+			t.Fatalf("expected a flat site to be crawled in full, but %s was refused", link)
+		}
+	}
+}
+
+func TestTrapDetectorPagesPerDir(t *testing.T) {
+	d := newTrapDetector()
+	d.MaxPagesPerDir = 2
+	cfg := trapConfig(d, normalizePolicy{})
+
+	// A page linking to the same URL twice counts it once
+	cfg.enqueueLinks([]string{"https://example.com/tags/go", "https://example.com/tags/go#top"}, 1)
+	for _, rawURL := range []string{"https://example.com/tags/web", "https://example.com/tags/go?utm_source=feed"} {
+		if linkRefused(cfg, rawURL) {
+			t.Fatalf("expected %s to be allowed", rawURL)
+		}
+	}
+
+	if !linkRefused(cfg, "https://example.com/tags/rust") {
+		t.Error("expected third distinct page in /tags/ to be flagged")
+	}
+
+	if linkRefused(cfg, "https://example.com/about") {
+		t.Error("expected other directories to be unaffected")
+	}
+}
+
+func TestCrawlStopsAtRelativeLinkTrap(t *testing.T) {
+	// Every page links to a relative "loop/" path, creating /loop/loop/loop/... forever
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><body><a href="loop/">Loop</a></body></html>`))
+	}))
+	defer server.Close()
+
+	baseURL, _ := url.Parse(server.URL)
+	store := newMemoryStore(nil)
+	cfg := &config{
+		pages:              store,
+		baseURL:            baseURL,
+		mu:                 &sync.Mutex{},
+		concurrencyControl: make(chan struct{}, 5),
+		wg:                 &sync.WaitGroup{},
+		traps:              newTrapDetector(),
+	}
+
+	cfg.enqueue(server.URL+"/", 0)
+	if err := cfg.crawl(); err != nil {
+		t.Fatal(err)
+	}
+
+	// "/", "/loop/" and "/loop/loop/" are allowed; the third repeat is a trap
+	if len(store.pages) != 3 {
+		t.Errorf("expected 3 pages before the trap was detected, got %d", len(store.pages))
+	}
+	traps := cfg.traps.suspectedTraps()
+	if len(traps) != 1 || !strings.HasSuffix(traps[0].URL, "/loop/loop/loop/") {
+		t.Errorf("expected /loop/loop/loop/ to be reported as a trap, got %+v", traps)
+	}
+}